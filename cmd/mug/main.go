@@ -91,6 +91,7 @@ func init() {
 	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(genCmd)
 	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(openapiCmd)
 	// rootCmd.AddCommand(buildCmd)
 	// rootCmd.AddCommand(makeCmd)
 }
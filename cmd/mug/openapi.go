@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/sh-lucas/mug/internal/apidiff"
+	"github.com/sh-lucas/mug/internal/config"
+	"github.com/sh-lucas/mug/internal/generator/openapi"
+	"github.com/sh-lucas/mug/internal/generator/router"
+	"github.com/sh-lucas/mug/pkg"
+	"github.com/spf13/cobra"
+)
+
+var openapiFlags struct {
+	Output string
+	Format string
+	Check  bool
+}
+
+var openapiCmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Exports the OpenAPI spec without starting the server.",
+	Long:  "Generates the router and writes the OpenAPI spec of every handler to a file (`-o -` for stdout). Use --check in CI to fail when the committed spec is out of date.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := openapiFlags.Output
+		if openapiFlags.Check && output == "-" {
+			fmt.Println(pkg.Red + "❌ --check compares the spec with a file; pass it with -o <file>, not -o -" + pkg.Reset)
+			os.Exit(1)
+		}
+		format := openapiFlags.Format
+		if format == "" {
+			format = formatFromPath(output)
+		}
+
+		router.GenerateRouter()
+		spec, err := openapi.Export(format)
+		if err != nil {
+			fmt.Printf(pkg.Red+"❌ Could not export the spec: %s\n"+pkg.Reset, err)
+			os.Exit(1)
+		}
+
+		if openapiFlags.Check {
+			current, err := os.ReadFile(output)
+			if err != nil || !bytes.Equal(current, spec) {
+				fmt.Printf(pkg.Red+"❌ %s is out of date; run `mug openapi -o %s`\n"+pkg.Reset, output, output)
+				os.Exit(1)
+			}
+			fmt.Printf(pkg.Green+"✅ %s is up to date\n"+pkg.Reset, output)
+			return
+		}
+
+		if output == "-" {
+			os.Stdout.Write(spec)
+			return
+		}
+		if err := os.WriteFile(output, spec, 0644); err != nil {
+			fmt.Printf(pkg.Red+"❌ Could not write %s: %s\n"+pkg.Reset, output, err)
+			os.Exit(1)
+		}
+		fmt.Printf(pkg.Green+"✅ OpenAPI spec written to %s\n"+pkg.Reset, output)
+	},
+}
+
+var openapiDiffCmd = &cobra.Command{
+	Use:   "diff <base-ref|file>",
+	Short: "Lists the changes between a previous spec and the current one.",
+	Long:  "Compares the current spec with a spec file, or with the committed output file (`openapi.output`) at a git ref, and classifies each change as breaking or not. Exits with 1 on breaking changes.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		base, err := loadBaseSpec(args[0])
+		if err != nil {
+			fmt.Printf(pkg.Red+"❌ Could not load the base spec: %s\n"+pkg.Reset, err)
+			os.Exit(1)
+		}
+
+		router.GenerateRouter()
+		data, err := openapi.Export("json")
+		if err != nil {
+			fmt.Printf(pkg.Red+"❌ Could not export the spec: %s\n"+pkg.Reset, err)
+			os.Exit(1)
+		}
+		current, err := apidiff.Load(data)
+		if err != nil {
+			fmt.Printf(pkg.Red+"❌ Could not load the current spec: %s\n"+pkg.Reset, err)
+			os.Exit(1)
+		}
+
+		changes := apidiff.Compare(base, current)
+		if len(changes) == 0 {
+			fmt.Println(pkg.Green + "✅ No changes in the API" + pkg.Reset)
+			return
+		}
+		for _, change := range changes {
+			if change.Breaking {
+				fmt.Println(pkg.Red + "✗ breaking      " + pkg.Reset + change.String())
+			} else {
+				fmt.Println(pkg.Green + "✓ non-breaking  " + pkg.Reset + change.String())
+			}
+		}
+		if apidiff.HasBreaking(changes) {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadBaseSpec reads the spec from a file if it exists,
+// otherwise from the output file as it was committed at the git ref.
+func loadBaseSpec(arg string) (*openapi3.T, error) {
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		// "./" makes git resolve the path from the current folder, not the repo root
+		data, err = exec.Command("git", "show", arg+":./"+openapiFlags.Output).Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither a file nor a git ref with %s committed", arg, openapiFlags.Output)
+		}
+	}
+	return apidiff.Load(data)
+}
+
+// formatFromPath picks json for .json files and yaml for everything else.
+func formatFromPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return "json"
+	}
+	return "yaml"
+}
+
+func init() {
+	openapiCmd.AddCommand(openapiDiffCmd)
+	openapiCmd.PersistentFlags().StringVarP(&openapiFlags.Output, "output", "o", config.Global.OpenAPI.Output, "file to write the spec to")
+	openapiCmd.Flags().StringVar(&openapiFlags.Format, "format", "", "json or yaml (defaults to the output's extension)")
+	openapiCmd.Flags().BoolVar(&openapiFlags.Check, "check", false, "fails if the output file differs from the current spec")
+}
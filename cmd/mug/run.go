@@ -25,8 +25,12 @@ func getBuildCmd() *exec.Cmd {
 }
 
 // if the config allows it, injects the environment variables from the envs map.
+// Apps run by mug are in development, unless MUG_ENV says otherwise.
 func injectEnvs(cmd *exec.Cmd) {
 	cmd.Env = os.Environ()
+	if os.Getenv("MUG_ENV") == "" {
+		cmd.Env = append(cmd.Env, "MUG_ENV=dev")
+	}
 
 	if config.Global.Watch.InjectEnvs != "" {
 		envs, err := godotenv.Read(config.Global.Watch.InjectEnvs)
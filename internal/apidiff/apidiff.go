@@ -0,0 +1,419 @@
+package apidiff
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Change is a single difference between two versions of a spec.
+type Change struct {
+	Breaking  bool
+	Operation string // e.g. "POST /users"
+	Message   string
+}
+
+func (c Change) String() string {
+	if c.Operation == "" {
+		return c.Message
+	}
+	return fmt.Sprintf("%s: %s", c.Operation, c.Message)
+}
+
+// direction tells whether a schema is sent by the client (request) or the server (response).
+// The same change may break one side but not the other:
+// a new enum value is fine in a request, but breaks clients reading it from a response.
+type direction int
+
+const (
+	request direction = iota
+	response
+)
+
+// Load parses a json or yaml spec, resolving its $refs.
+func Load(data []byte) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	return loader.LoadFromData(data)
+}
+
+// Compare lists every change from base to current, sorted by operation.
+func Compare(base, current *openapi3.T) []Change {
+	d := &differ{}
+
+	basePaths := pathsOf(base)
+	currentPaths := pathsOf(current)
+
+	for _, path := range sortedKeys(basePaths) {
+		baseItem := basePaths[path]
+		currentItem, ok := currentPaths[path]
+		if !ok {
+			d.breaking("", "path %s removed", path)
+			continue
+		}
+
+		baseOps := baseItem.Operations()
+		currentOps := currentItem.Operations()
+		for _, method := range sortedKeys(baseOps) {
+			op := method + " " + path
+			currentOp, ok := currentOps[method]
+			if !ok {
+				d.breaking(op, "operation removed")
+				continue
+			}
+			d.operation(op, baseOps[method], currentOp)
+		}
+		for _, method := range sortedKeys(currentOps) {
+			if _, ok := baseOps[method]; !ok {
+				d.compatible(method+" "+path, "operation added")
+			}
+		}
+	}
+
+	for _, path := range sortedKeys(currentPaths) {
+		if _, ok := basePaths[path]; !ok {
+			d.compatible("", "path %s added", path)
+		}
+	}
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		return d.changes[i].Operation < d.changes[j].Operation
+	})
+	return d.changes
+}
+
+// HasBreaking reports whether any of the changes is breaking.
+func HasBreaking(changes []Change) bool {
+	return slices.ContainsFunc(changes, func(c Change) bool { return c.Breaking })
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) breaking(op, format string, args ...any) {
+	d.changes = append(d.changes, Change{Breaking: true, Operation: op, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *differ) compatible(op, format string, args ...any) {
+	d.changes = append(d.changes, Change{Breaking: false, Operation: op, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *differ) operation(op string, base, current *openapi3.Operation) {
+	d.parameters(op, base.Parameters, current.Parameters)
+	d.requestBody(op, base.RequestBody, current.RequestBody)
+	d.responses(op, base.Responses, current.Responses)
+
+	if !secured(base) && secured(current) {
+		d.breaking(op, "authentication is now required")
+	}
+	if !base.Deprecated && current.Deprecated {
+		d.compatible(op, "operation deprecated")
+	}
+}
+
+func (d *differ) parameters(op string, base, current openapi3.Parameters) {
+	for _, ref := range base {
+		param := ref.Value
+		if param == nil {
+			continue
+		}
+		cur := current.GetByInAndName(param.In, param.Name)
+		where := fmt.Sprintf("%s parameter %q", param.In, param.Name)
+		if cur == nil {
+			d.compatible(op, "%s removed", where)
+			continue
+		}
+		if !param.Required && cur.Required {
+			d.breaking(op, "%s is now required", where)
+		}
+		d.schema(op, where, param.Schema, cur.Schema, request, map[[2]*openapi3.Schema]bool{})
+	}
+	for _, ref := range current {
+		param := ref.Value
+		if param == nil || base.GetByInAndName(param.In, param.Name) != nil {
+			continue
+		}
+		where := fmt.Sprintf("%s parameter %q", param.In, param.Name)
+		if param.Required {
+			d.breaking(op, "required %s added", where)
+		} else {
+			d.compatible(op, "optional %s added", where)
+		}
+	}
+}
+
+func (d *differ) requestBody(op string, base, current *openapi3.RequestBodyRef) {
+	switch {
+	case base == nil && current == nil:
+		return
+	case base == nil:
+		if current.Value != nil && current.Value.Required {
+			d.breaking(op, "required request body added")
+		} else {
+			d.compatible(op, "request body added")
+		}
+		return
+	case current == nil:
+		d.compatible(op, "request body removed")
+		return
+	}
+	if base.Value == nil || current.Value == nil {
+		return
+	}
+	if !base.Value.Required && current.Value.Required {
+		d.breaking(op, "request body is now required")
+	} else if base.Value.Required && !current.Value.Required {
+		d.compatible(op, "request body is now optional")
+	}
+	d.content(op, "request body", base.Value.Content, current.Value.Content, request)
+}
+
+func (d *differ) responses(op string, base, current *openapi3.Responses) {
+	baseMap := base.Map()
+	currentMap := current.Map()
+
+	for _, status := range sortedKeys(baseMap) {
+		cur, ok := currentMap[status]
+		if !ok {
+			d.breaking(op, "response %s removed", status)
+			continue
+		}
+		if baseMap[status].Value == nil || cur.Value == nil {
+			continue
+		}
+		d.content(op, "response "+status, baseMap[status].Value.Content, cur.Value.Content, response)
+	}
+	for _, status := range sortedKeys(currentMap) {
+		if _, ok := baseMap[status]; !ok {
+			d.compatible(op, "response %s added", status)
+		}
+	}
+}
+
+func (d *differ) content(op, where string, base, current openapi3.Content, dir direction) {
+	for _, mime := range sortedKeys(base) {
+		cur, ok := current[mime]
+		if !ok {
+			if dir == request {
+				d.breaking(op, "%s no longer accepts %s", where, mime)
+			} else {
+				d.breaking(op, "%s no longer returns %s", where, mime)
+			}
+			continue
+		}
+		d.schema(op, where, base[mime].Schema, cur.Schema, dir, map[[2]*openapi3.Schema]bool{})
+	}
+}
+
+// schema compares two schemas recursively.
+// visited guards against recursive types, which would loop forever.
+func (d *differ) schema(op, where string, baseRef, currentRef *openapi3.SchemaRef, dir direction, visited map[[2]*openapi3.Schema]bool) {
+	if baseRef == nil || currentRef == nil || baseRef.Value == nil || currentRef.Value == nil {
+		return
+	}
+	base, current := baseRef.Value, currentRef.Value
+	if visited[[2]*openapi3.Schema{base, current}] {
+		return
+	}
+	visited[[2]*openapi3.Schema{base, current}] = true
+
+	if baseType, currentType := typeOf(base), typeOf(current); baseType != currentType {
+		switch {
+		case baseType != "":
+			d.breaking(op, "%s changed type from %s to %s", where, baseType, orAny(currentType))
+			return // nothing else is comparable
+		case dir == request:
+			d.breaking(op, "%s now only accepts %s", where, currentType)
+		default:
+			d.compatible(op, "%s now only returns %s", where, currentType)
+		}
+	}
+	if base.Format != current.Format && base.Format != "" {
+		d.breaking(op, "%s changed format from %q to %q", where, base.Format, current.Format)
+	}
+
+	d.enum(op, where, base.Enum, current.Enum, dir)
+
+	// properties
+	for _, name := range sortedKeys(base.Properties) {
+		field := fmt.Sprintf("%s field %q", where, name)
+		cur, ok := current.Properties[name]
+		if !ok {
+			d.breaking(op, "%s removed", field)
+			continue
+		}
+
+		wasRequired := slices.Contains(base.Required, name)
+		isRequired := slices.Contains(current.Required, name)
+		if dir == request && !wasRequired && isRequired {
+			d.breaking(op, "%s is now required", field)
+		}
+		if dir == response && wasRequired && !isRequired {
+			d.breaking(op, "%s may now be missing", field)
+		}
+
+		d.schema(op, field, base.Properties[name], cur, dir, visited)
+	}
+	for _, name := range sortedKeys(current.Properties) {
+		if _, ok := base.Properties[name]; ok {
+			continue
+		}
+		field := fmt.Sprintf("%s field %q", where, name)
+		if dir == request && slices.Contains(current.Required, name) {
+			d.breaking(op, "required %s added", field)
+		} else {
+			d.compatible(op, "%s added", field)
+		}
+	}
+
+	d.schema(op, where+" items", base.Items, current.Items, dir, visited)
+
+	d.composition(op, where, "oneOf", base.OneOf, current.OneOf, dir, visited)
+	d.composition(op, where, "anyOf", base.AnyOf, current.AnyOf, dir, visited)
+	d.composition(op, where, "allOf", base.AllOf, current.AllOf, dir, visited)
+}
+
+// composition compares the schemas of a oneOf, anyOf or allOf, matched by $ref or type.
+// Those of a oneOf or anyOf are alternatives: removing one narrows what's allowed, adding
+// one widens it. Those of an allOf must all match, so it's the other way around, as it is
+// for a oneOf or anyOf appearing or vanishing altogether.
+func (d *differ) composition(op, where, kind string, base, current openapi3.SchemaRefs, dir direction, visited map[[2]*openapi3.Schema]bool) {
+	alternatives := kind != "allOf" && len(base) > 0 && len(current) > 0
+	baseSchemas, currentSchemas := schemasByKey(base), schemasByKey(current)
+
+	for _, key := range sortedKeys(baseSchemas) {
+		cur, ok := currentSchemas[key]
+		if !ok {
+			// fewer alternatives, or fewer constraints
+			if alternatives == (dir == request) {
+				d.breaking(op, "%s no longer %s %s %s", where, verb(dir), kind, key)
+			} else {
+				d.compatible(op, "%s %s %s removed", where, kind, key)
+			}
+			continue
+		}
+		d.schema(op, where+" "+kind+" "+key, baseSchemas[key], cur, dir, visited)
+	}
+	for _, key := range sortedKeys(currentSchemas) {
+		if _, ok := baseSchemas[key]; ok {
+			continue
+		}
+		// more alternatives, or more constraints
+		if alternatives == (dir == response) {
+			d.breaking(op, "%s %s %s added %s", where, kind, key, addedNote(dir))
+		} else {
+			d.compatible(op, "%s %s %s added", where, kind, key)
+		}
+	}
+}
+
+// verb says what a schema does with its values, from the client's point of view.
+func verb(dir direction) string {
+	if dir == request {
+		return "accepts"
+	}
+	return "returns"
+}
+
+// addedNote says why a schema added to a composition breaks clients.
+func addedNote(dir direction) string {
+	if dir == request {
+		return "(now required)"
+	}
+	return "(may now be returned)"
+}
+
+// schemasByKey keys the schemas of a composition by their $ref's name, or else by their type,
+// so reordering them isn't a change.
+func schemasByKey(refs openapi3.SchemaRefs) map[string]*openapi3.SchemaRef {
+	schemas := map[string]*openapi3.SchemaRef{}
+	for i, ref := range refs {
+		key := path.Base(ref.Ref)
+		if ref.Ref == "" && ref.Value != nil {
+			key = orAny(typeOf(ref.Value))
+		}
+		if _, taken := schemas[key]; taken || key == "" {
+			key = fmt.Sprintf("%s#%d", key, i+1)
+		}
+		schemas[key] = ref
+	}
+	return schemas
+}
+
+func (d *differ) enum(op, where string, base, current []any, dir direction) {
+	if len(base) == 0 && len(current) == 0 {
+		return
+	}
+	removed := missingFrom(base, current)
+	added := missingFrom(current, base)
+
+	// an enum that appears restricts the values; one that vanishes allows anything
+	if len(base) == 0 {
+		added, removed = nil, []any{"any value"}
+	} else if len(current) == 0 {
+		removed, added = nil, []any{"any value"}
+	}
+
+	if len(removed) > 0 {
+		if dir == request {
+			d.breaking(op, "%s no longer accepts %v", where, removed)
+		} else {
+			d.compatible(op, "%s no longer returns %v", where, removed)
+		}
+	}
+	if len(added) > 0 {
+		if dir == response {
+			d.breaking(op, "%s may now return %v", where, added)
+		} else {
+			d.compatible(op, "%s now accepts %v", where, added)
+		}
+	}
+}
+
+func secured(op *openapi3.Operation) bool {
+	return op.Security != nil && len(*op.Security) > 0
+}
+
+func pathsOf(spec *openapi3.T) map[string]*openapi3.PathItem {
+	if spec == nil || spec.Paths == nil {
+		return map[string]*openapi3.PathItem{}
+	}
+	return spec.Paths.Map()
+}
+
+func typeOf(schema *openapi3.Schema) string {
+	if schema.Type == nil {
+		return ""
+	}
+	return strings.Join(schema.Type.Slice(), "|")
+}
+
+func orAny(typ string) string {
+	if typ == "" {
+		return "any"
+	}
+	return typ
+}
+
+// missingFrom returns the values of a that are not in b.
+func missingFrom(a, b []any) (missing []any) {
+	for _, v := range a {
+		if !slices.ContainsFunc(b, func(w any) bool { return fmt.Sprint(v) == fmt.Sprint(w) }) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"log"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -45,7 +46,78 @@ type config struct {
 		Router  bool `yaml:"router"`
 		Envs    bool `yaml:"envs"`
 		Swagger bool `yaml:"swagger"`
+		Metrics bool `yaml:"metrics"`
+		Tracing bool `yaml:"tracing"`
+		Health  bool `yaml:"health"`
 	} `yaml:"gen"`
+	OpenAPI struct {
+		Output   string `yaml:"output"`
+		Title    string `yaml:"title"`
+		Version  string `yaml:"version"`
+		Renderer string `yaml:"renderer"`
+		Assets   string `yaml:"assets"`
+		Servers  []struct {
+			URL         string `yaml:"url"`
+			Description string `yaml:"description"`
+		} `yaml:"servers"`
+	} `yaml:"openapi"`
+	Brew   Brew   `yaml:"brew"`
+	Server Server `yaml:"server"`
+}
+
+// Server is the server section, printed in the router as a spout.ServerConfig.
+// The sections mirror the library's types, so the CLI doesn't link the library.
+type Server struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	CertFile          string        `yaml:"cert_file"`
+	KeyFile           string        `yaml:"key_file"`
+	Socket            string        `yaml:"socket"`
+}
+
+// Brew is the brew section, printed in the router as a brew.Config.
+type Brew struct {
+	Global    []string `yaml:"global"`
+	RequestID struct {
+		Header string `yaml:"header"`
+	} `yaml:"request_id"`
+	AccessLog struct {
+		Skip []string `yaml:"skip"`
+	} `yaml:"access_log"`
+	Recover struct {
+		Stack bool `yaml:"stack"`
+	} `yaml:"recover"`
+	CORS struct {
+		AllowedOrigins   []string      `yaml:"allowed_origins"`
+		AllowedMethods   []string      `yaml:"allowed_methods"`
+		AllowedHeaders   []string      `yaml:"allowed_headers"`
+		ExposedHeaders   []string      `yaml:"exposed_headers"`
+		AllowCredentials bool          `yaml:"allow_credentials"`
+		MaxAge           time.Duration `yaml:"max_age"`
+	} `yaml:"cors"`
+	Compress struct {
+		Encodings []string `yaml:"encodings"`
+		MinSize   int      `yaml:"min_size"`
+		Types     []string `yaml:"types"`
+	} `yaml:"compress"`
+	Timeout   time.Duration `yaml:"timeout"`
+	BodyLimit int64         `yaml:"body_limit"`
+	RealIP    struct {
+		Headers        []string `yaml:"headers"`
+		TrustedProxies []string `yaml:"trusted_proxies"`
+	} `yaml:"real_ip"`
+	SecureHeaders struct {
+		ContentTypeOptions      string `yaml:"content_type_options"`
+		FrameOptions            string `yaml:"frame_options"`
+		ReferrerPolicy          string `yaml:"referrer_policy"`
+		StrictTransportSecurity string `yaml:"strict_transport_security"`
+		ContentSecurityPolicy   string `yaml:"content_security_policy"`
+		PermissionsPolicy       string `yaml:"permissions_policy"`
+	} `yaml:"secure_headers"`
 }
 
 var Global = config{}
@@ -65,7 +137,10 @@ func init() {
 	setConfig(cfgFile)
 }
 
+// setConfig loads the defaults first, so keys missing from
+// the user's file (e.g. sections added in newer versions) keep their default value.
 func setConfig(file []byte) {
+	_ = yaml.Unmarshal(defaultFile, &Global)
 	err := yaml.Unmarshal(file, &Global)
 	if err != nil {
 		log.Fatalf("Could not load config file %s: %v", defaultConfigName, err)
@@ -0,0 +1,69 @@
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sh-lucas/mug/internal/config"
+	"github.com/sh-lucas/mug/internal/generator"
+	"github.com/sh-lucas/mug/internal/helpers"
+)
+
+//go:embed openapi.go.tmpl
+var exporterTemplate string
+
+type genData struct {
+	Router string // import path of the generated router
+}
+
+// Export generates a small program inside cup/openapi that registers
+// every handler and writes the spec, then runs it and returns its output.
+// The router package must already be generated.
+func Export(format string) ([]byte, error) {
+	if _, err := os.Stat(filepath.Join("cup", "router", "router.go")); err != nil {
+		return nil, fmt.Errorf("no generated router found; are there handlers annotated with // mug:handler?")
+	}
+
+	routerPkg, err := importPath("./cup/router")
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Logf("Generating openapi exporter")
+	if err := generator.Generate(exporterTemplate, genData{Router: routerPkg}, "openapi", "main.go"); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "mug-openapi-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	cmd := exec.Command("go", "run", "./cup/openapi", "-format", format, "-o", tmp.Name())
+	cmd.Stderr = os.Stderr
+	// the routes printed while registering are only noise here
+	if config.Global.Debug {
+		cmd.Stdout = os.Stdout
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run the openapi exporter: %v", err)
+	}
+
+	return os.ReadFile(tmp.Name())
+}
+
+// importPath asks the go tool for the import path of a local package,
+// so it works whether or not the project root is the module root.
+func importPath(dir string) (string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", dir).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the import path of %s: %v", dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
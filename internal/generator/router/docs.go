@@ -0,0 +1,247 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sh-lucas/mug/pkg"
+)
+
+// handlerDoc is the OpenAPI documentation read from a handler's doc comment.
+// It's printed as a spout.Meta literal in the generated router.
+type handlerDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+	Responses   []response
+
+	RequestExample   string
+	ResponseExamples map[int]string
+
+	Version string // from the handler's folder
+
+	Require requirement
+}
+
+type response struct {
+	Code        int
+	Description string
+}
+
+// requirement is a spout.Requirement: any of the roles, all of the scopes.
+type requirement struct {
+	Roles  []string
+	Scopes []string
+}
+
+func (r requirement) empty() bool {
+	return len(r.Roles) == 0 && len(r.Scopes) == 0
+}
+
+// parse reads the value of // mug:require role=admin,support scope=orders:write
+func (r *requirement) parse(value, annotation string) {
+	for _, field := range strings.Fields(value) {
+		key, list, _ := strings.Cut(field, "=")
+		values := slices.DeleteFunc(strings.Split(list, ","), func(v string) bool { return v == "" })
+		if len(values) == 0 {
+			key = "" // falls to the error
+		}
+		switch key {
+		case "role", "roles":
+			r.Roles = append(r.Roles, values...)
+		case "scope", "scopes":
+			r.Scopes = append(r.Scopes, values...)
+		default:
+			log.Fatalf(pkg.Red+"Invalid annotation %q: expected // mug:require role=<role>[,<role>] scope=<scope>[,<scope>]"+pkg.Reset, annotation)
+		}
+	}
+}
+
+// parseDoc splits a handler's doc comment into documentation and annotations.
+// The first line of text is the summary and the remaining lines the description;
+// `// mug:handler`, middleware (`// >`) and other annotation lines are not part of the text.
+//
+//	// mug:tag users admin
+//	// mug:operationId createUser
+//	// mug:response 201 Created
+//	// mug:deprecated
+//	// mug:require role=admin scope=orders:write
+func parseDoc(comment *ast.CommentGroup) (doc handlerDoc) {
+	var text []string
+
+	for _, c := range comment.List {
+		line := strings.TrimPrefix(c.Text, "//")
+		line = strings.TrimPrefix(line, " ")
+
+		if strings.HasPrefix(line, ">") {
+			continue // middlewares
+		}
+
+		annotation, ok := strings.CutPrefix(line, "mug:")
+		if !ok {
+			text = append(text, line)
+			continue
+		}
+
+		name, value, _ := strings.Cut(annotation, " ")
+		value = strings.TrimSpace(value)
+		switch name {
+		case "tag", "tags":
+			doc.Tags = append(doc.Tags, strings.Fields(value)...)
+		case "operationId":
+			doc.OperationID = value
+		case "deprecated":
+			doc.Deprecated = true
+		case "response":
+			codeStr, description, _ := strings.Cut(value, " ")
+			code, err := strconv.Atoi(codeStr)
+			if err != nil || code < 100 || code > 599 {
+				log.Fatalf(pkg.Red+"Invalid annotation %q: expected // mug:response <status code> [description]"+pkg.Reset, c.Text)
+			}
+			doc.Responses = append(doc.Responses, response{Code: code, Description: strings.TrimSpace(description)})
+		case "require":
+			doc.Require.parse(value, c.Text)
+		}
+	}
+
+	// the summary is the first line with something written
+	for len(text) > 0 && strings.TrimSpace(text[0]) == "" {
+		text = text[1:]
+	}
+	if len(text) > 0 {
+		doc.Summary = strings.TrimSpace(text[0])
+		doc.Description = strings.TrimSpace(strings.Join(text[1:], "\n"))
+	}
+	return doc
+}
+
+// loadExamples reads the JSON examples of the handler from the examples folder
+// next to it. Files are named after the function or its operationId:
+//
+//	examples/CreateUser.request.json  // request body
+//	examples/CreateUser.response.json // success response
+//	examples/CreateUser.409.json      // response with a given status
+func (d *handlerDoc) loadExamples(dir, fnName string) {
+	names := []string{fnName}
+	if d.OperationID != "" {
+		names = append(names, d.OperationID)
+	}
+
+	for _, name := range names {
+		files, _ := filepath.Glob(filepath.Join(dir, "examples", name+".*.json"))
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, content); err != nil {
+				log.Fatalf(pkg.Red+"Invalid JSON in example %s: %v"+pkg.Reset, file, err)
+			}
+
+			kind := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), name+"."), ".json")
+			switch kind {
+			case "request":
+				d.RequestExample = compact.String()
+			case "response":
+				d.setResponseExample(d.successCode(), compact.String())
+			default:
+				if code, err := strconv.Atoi(kind); err == nil {
+					d.setResponseExample(code, compact.String())
+				}
+			}
+		}
+	}
+}
+
+func (d *handlerDoc) setResponseExample(code int, example string) {
+	if d.ResponseExamples == nil {
+		d.ResponseExamples = map[int]string{}
+	}
+	d.ResponseExamples[code] = example
+}
+
+// successCode is the first non-error status declared, or 200 as spout documents by default.
+func (d handlerDoc) successCode() int {
+	for _, r := range d.Responses {
+		if r.Code < 400 {
+			return r.Code
+		}
+	}
+	return 200
+}
+
+func (d handlerDoc) empty() bool {
+	return d.Summary == "" && d.Description == "" && len(d.Tags) == 0 &&
+		d.OperationID == "" && !d.Deprecated && len(d.Responses) == 0 &&
+		d.RequestExample == "" && len(d.ResponseExamples) == 0 && d.Version == "" && d.Require.empty()
+}
+
+// literal prints the doc as a spout.Meta composite literal, skipping empty fields.
+func (d handlerDoc) literal() string {
+	fields := []string{}
+	if d.Summary != "" {
+		fields = append(fields, "Summary: "+strconv.Quote(d.Summary))
+	}
+	if d.Description != "" {
+		fields = append(fields, "Description: "+strconv.Quote(d.Description))
+	}
+	if len(d.Tags) > 0 {
+		fields = append(fields, "Tags: "+quotedList(d.Tags))
+	}
+	if d.OperationID != "" {
+		fields = append(fields, "OperationID: "+strconv.Quote(d.OperationID))
+	}
+	if d.Deprecated {
+		fields = append(fields, "Deprecated: true")
+	}
+	if len(d.Responses) > 0 {
+		responses := make([]string, len(d.Responses))
+		for i, r := range d.Responses {
+			responses[i] = fmt.Sprintf("{Code: %d, Description: %s}", r.Code, strconv.Quote(r.Description))
+		}
+		fields = append(fields, fmt.Sprintf("Responses: []spout.Response{%s}", strings.Join(responses, ", ")))
+	}
+	if d.RequestExample != "" {
+		fields = append(fields, "RequestExample: "+strconv.Quote(d.RequestExample))
+	}
+	if len(d.ResponseExamples) > 0 {
+		codes := slices.Sorted(maps.Keys(d.ResponseExamples))
+		examples := make([]string, len(codes))
+		for i, code := range codes {
+			examples[i] = fmt.Sprintf("%d: %s", code, strconv.Quote(d.ResponseExamples[code]))
+		}
+		fields = append(fields, fmt.Sprintf("ResponseExamples: map[int]string{%s}", strings.Join(examples, ", ")))
+	}
+	if d.Version != "" {
+		fields = append(fields, "Version: "+strconv.Quote(d.Version))
+	}
+	if !d.Require.empty() {
+		fields = append(fields, fmt.Sprintf("Require: spout.Requirement{Roles: %s, Scopes: %s}",
+			quotedList(d.Require.Roles), quotedList(d.Require.Scopes)))
+	}
+	return "spout.Meta{" + strings.Join(fields, ", ") + "}"
+}
+
+// quotedList prints a []string literal, nil when empty.
+func quotedList(list []string) string {
+	if len(list) == 0 {
+		return "nil"
+	}
+	quoted := make([]string, len(list))
+	for i, item := range list {
+		quoted[i] = strconv.Quote(item)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
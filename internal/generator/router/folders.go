@@ -0,0 +1,192 @@
+package router
+
+import (
+	"fmt"
+	"go/ast"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg"
+)
+
+// folder holds the package-level annotations of a handlers folder,
+// written on the package clause of any of its files (usually a doc.go):
+//
+//	// mug:version v1 deprecated=2025-01-31 sunset=2025-12-31
+//	package v1
+//
+//	// mug:group /admin > AuthMiddleware > AuditMiddleware
+//	package admin
+//
+// Subfolders inherit them, so everything under handlers/v1 is mounted under /v1,
+// and groups nest: their prefixes are joined and their middlewares chained.
+type folder struct {
+	Version     *apiVersion
+	Prefix      string       // of the groups, after the version's
+	Middlewares []middleware // of the groups, before the handler's own
+}
+
+// apiVersion is a version prefix; handlers/v2 folders get one without annotating.
+type apiVersion struct {
+	Name         string
+	Deprecated   bool
+	DeprecatedAt time.Time
+	Sunset       time.Time
+}
+
+var versionFolder = regexp.MustCompile(`^v[0-9]+$`)
+
+// child returns the folder at dir: f's annotations overridden by the ones of the package at dir,
+// written on the package clauses of files.
+func (f folder) child(dir string, files []*ast.File) folder {
+	if name := filepath.Base(dir); versionFolder.MatchString(name) {
+		f.Version = &apiVersion{Name: name}
+	}
+
+	for _, file := range files {
+		if file.Doc == nil {
+			continue
+		}
+		for _, c := range file.Doc.List {
+			line := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+			annotation, ok := strings.CutPrefix(line, "mug:")
+			if !ok {
+				continue
+			}
+			name, value, _ := strings.Cut(annotation, " ")
+			switch name {
+			case "version":
+				f.Version = parseVersion(c.Text, value)
+			case "group":
+				prefix, middlewares := parseGroup(c.Text, value, importsOf(file))
+				f.Prefix += prefix
+				// cloned, so sibling folders don't share the backing array
+				f.Middlewares = append(slices.Clone(f.Middlewares), middlewares...)
+			}
+		}
+	}
+	return f
+}
+
+// parseVersion reads `v1 [deprecated[=YYYY-MM-DD]] [sunset=YYYY-MM-DD]`.
+func parseVersion(comment, value string) *apiVersion {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || strings.ContainsAny(fields[0], "/{}= ") {
+		log.Fatalf(pkg.Red+"Invalid annotation %q: expected // mug:version <name> [deprecated[=YYYY-MM-DD]] [sunset=YYYY-MM-DD]"+pkg.Reset, comment)
+	}
+
+	version := &apiVersion{Name: fields[0]}
+	for _, field := range fields[1:] {
+		key, date, hasDate := strings.Cut(field, "=")
+		var at time.Time
+		if hasDate {
+			var err error
+			if at, err = time.Parse(time.DateOnly, date); err != nil {
+				log.Fatalf(pkg.Red+"Invalid date %q in %q: expected YYYY-MM-DD"+pkg.Reset, date, comment)
+			}
+		}
+
+		switch key {
+		case "deprecated":
+			version.Deprecated = true
+			version.DeprecatedAt = at
+		case "sunset":
+			if !hasDate {
+				log.Fatalf(pkg.Red+"Invalid annotation %q: sunset needs a date, like sunset=2025-12-31"+pkg.Reset, comment)
+			}
+			version.Sunset = at
+		default:
+			log.Fatalf(pkg.Red+"Unknown option %q in %q"+pkg.Reset, field, comment)
+		}
+	}
+	return version
+}
+
+// parseGroup reads `[/prefix] [> Middleware > ...]`, written in a file with these imports.
+func parseGroup(comment, value string, imports fileImports) (prefix string, middlewares []middleware) {
+	prefix, chain, _ := strings.Cut(value, ">")
+	prefix = strings.TrimSpace(prefix)
+	if prefix != "" && (!strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " {}")) {
+		log.Fatalf(pkg.Red+"Invalid annotation %q: expected // mug:group [/prefix] [> Middleware > ...]"+pkg.Reset, comment)
+	}
+	for _, mw := range splitMiddlewares(chain) {
+		middlewares = append(middlewares, parseMiddleware(mw, imports))
+	}
+	return strings.TrimSuffix(prefix, "/"), middlewares
+}
+
+// prefix is where the folder's routes are mounted, e.g. /v2/admin.
+func (f folder) prefix() string {
+	if f.Version == nil {
+		return f.Prefix
+	}
+	return "/" + f.Version.Name + f.Prefix
+}
+
+// mount prefixes the path of a route pattern, keeping its method:
+// "GET /users" becomes "GET /v2/users".
+func mount(pattern, prefix string) string {
+	if prefix == "" {
+		return pattern
+	}
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		return prefix + pattern
+	}
+	return method + " " + prefix + strings.TrimSpace(path)
+}
+
+// versionsLiteral prints the versions used by the handlers as a map of spout.APIVersion.
+// A version declared in several folders is deprecated if any of them says so.
+func versionsLiteral(decls []HandlerDecl) string {
+	versions := map[string]apiVersion{}
+	for _, decl := range decls {
+		v := decl.Folder.Version
+		if v == nil {
+			continue
+		}
+		merged := versions[v.Name]
+		merged.Deprecated = merged.Deprecated || v.Deprecated
+		if !v.DeprecatedAt.IsZero() {
+			merged.DeprecatedAt = v.DeprecatedAt
+		}
+		if !v.Sunset.IsZero() {
+			merged.Sunset = v.Sunset
+		}
+		versions[v.Name] = merged
+	}
+	if len(versions) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	entries := strings.Builder{}
+	for _, name := range names {
+		v := versions[name]
+		fields := []string{}
+		if v.Deprecated {
+			fields = append(fields, "Deprecated: true")
+		}
+		if !v.DeprecatedAt.IsZero() {
+			fields = append(fields, "DeprecatedAt: "+dateLiteral(v.DeprecatedAt))
+		}
+		if !v.Sunset.IsZero() {
+			fields = append(fields, "Sunset: "+dateLiteral(v.Sunset))
+		}
+		fmt.Fprintf(&entries, "%q: {%s},\n", name, strings.Join(fields, ", "))
+	}
+	return "map[string]spout.APIVersion{\n" + entries.String() + "}"
+}
+
+func dateLiteral(t time.Time) string {
+	return fmt.Sprintf("time.Date(%d, %d, %d, 0, 0, 0, 0, time.UTC)", t.Year(), t.Month(), t.Day())
+}
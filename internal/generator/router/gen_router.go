@@ -2,12 +2,18 @@ package router
 
 import (
 	_ "embed"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"log"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/sh-lucas/mug/internal/config"
@@ -24,11 +30,22 @@ type HandlerDecl struct {
 	Package string
 	Doc     *ast.CommentGroup // Documentation comment
 	Path    string
+	Dir     string      // folder of the handler's package
+	Folder  folder      // package-level annotations, inherited from the parent folders
+	Imports fileImports // of the handler's file, to resolve its middlewares
 }
 
 type genData struct {
+	Imports  string
 	Handlers string
 	Swagger  bool
+	Metrics  bool
+	Health   bool
+	Docs     string
+	Versions string
+	Brew     string // brew.Config literal, when the app uses brew
+	Tracing  string // quoted service name, when tracing is enabled
+	Server   string // spout.ServerConfig literal
 }
 
 func GenerateRouter() {
@@ -44,6 +61,8 @@ func GenerateRouter() {
 	}
 	helpers.Logf("Generating router package")
 
+	imports := aliasPackages(decls)
+	packages := newRouterImports(reservedImports(decls))
 	var content = &strings.Builder{}
 
 	for _, handler := range decls {
@@ -55,18 +74,40 @@ func GenerateRouter() {
 			}
 		}
 		// fmt.Printf(helpers.Yellow+"[%s] - %s%s\n"+helpers.Reset, handler.Fn.Name.Name, helpers.Cyan, path)
+		path = mount(path, handler.Folder.prefix())
 
 		handlerArgs := handler.Fn.Type.Params.List
 		if len(handlerArgs) > 0 && isResponseWriter(handlerArgs[0]) {
-			printBasicRouter(content, path, handler)
+			printBasicRouter(content, packages, path, handler)
 		} else {
-			printInjectRouter(content, path, handler)
+			printInjectRouter(content, packages, path, handler)
 		}
 	}
 
+	imports += packages.String()
+
+	brewConfig := brewConfigLiteral(decls)
+	if brewConfig != "" {
+		imports += strconv.Quote(brewPath) + "\n"
+	}
+
+	tracing := ""
+	if config.Global.Gen.Tracing {
+		tracing = strconv.Quote(serviceName())
+		imports += "\"github.com/sh-lucas/mug/pkg/tracing\"\n"
+	}
+
 	data := genData{
+		Imports:  imports,
 		Handlers: content.String(),
 		Swagger:  config.Global.Gen.Swagger,
+		Metrics:  config.Global.Gen.Metrics,
+		Health:   config.Global.Gen.Health,
+		Docs:     docsConfigLiteral(),
+		Versions: versionsLiteral(decls),
+		Brew:     brewConfig,
+		Tracing:  tracing,
+		Server:   serverConfigLiteral(),
 	}
 
 	err = generator.Generate(routerTemplate, data, "router", "router.go")
@@ -75,6 +116,15 @@ func GenerateRouter() {
 	}
 }
 
+// serviceName names the app in its traces after the last element of its module path.
+func serviceName() string {
+	module, err := helpers.ImportPath(".")
+	if err != nil {
+		return ""
+	}
+	return path.Base(module)
+}
+
 func isResponseWriter(field *ast.Field) bool {
 	selector, ok := field.Type.(*ast.SelectorExpr)
 	if !ok {
@@ -96,30 +146,42 @@ func parseHandlersFolder() (decls []HandlerDecl, err error) {
 
 	handlersDir := filepath.Join(execPath, "handlers")
 
-	// parse the subfolders
-	helpers.Walk(handlersDir, func(filepath string) {
-		handlerDecls, err := getCommentsFromFolder(filepath)
+	// parse the subfolders; folders are walked before their subfolders,
+	// so the parent's annotations are known when a folder is reached.
+	folders := map[string]folder{}
+	helpers.Walk(handlersDir, func(dir string) {
+		handlerDecls, files, err := getCommentsFromFolder(dir)
 		if err != nil {
-			log.Printf("Error parsing handler %s: %v", filepath, err)
+			log.Printf("Error parsing handler %s: %v", dir, err)
 		}
 
+		folders[dir] = folders[filepath.Dir(dir)].child(dir, files)
+		for i := range handlerDecls {
+			handlerDecls[i].Folder = folders[dir]
+		}
 		decls = append(decls, handlerDecls...)
 	})
 
 	return decls, err
 }
 
-func getCommentsFromFolder(handlersDir string) (decls []HandlerDecl, err error) {
+// getCommentsFromFolder returns the handlers of the package in handlersDir
+// and its files, whose package clauses hold the folder's annotations.
+func getCommentsFromFolder(handlersDir string) (decls []HandlerDecl, files []*ast.File, err error) {
 	fset := token.NewFileSet()
 	pkgs, err := parser.ParseDir(fset, handlersDir, nil, parser.ParseComments)
 	if err != nil || len(pkgs) != 1 {
-		return []HandlerDecl{}, nil // empty folder, ignore it
+		return []HandlerDecl{}, nil, nil // empty folder, ignore it
 	}
 
 	// there should be 1 package inside
 	for pkgName, pkg := range pkgs {
 		helpers.Logf("Found handler package %s", pkgName)
-		for _, file := range pkg.Files {
+		// sorted, so the generated router doesn't change between runs
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[name]
+			files = append(files, file)
+			found := len(decls)
 			// for every declaration in the file
 			for _, decl := range file.Decls {
 				// if the declaration is a function declaration
@@ -127,11 +189,82 @@ func getCommentsFromFolder(handlersDir string) (decls []HandlerDecl, err error)
 					ParseFunc(pkgName, funcDecl, &decls)
 				}
 			}
+			for i := found; i < len(decls); i++ {
+				decls[i].Imports = importsOf(file)
+			}
 		}
 	}
-	return decls, nil
+	for i := range decls {
+		decls[i].Dir = handlersDir
+	}
+	return decls, files, nil
 }
 
+// aliasPackages prints the imports of the handler packages.
+// Packages sharing a name, like handlers/v1/user and handlers/v2/user,
+// are imported with an alias made of their path, e.g. v2user.
+func aliasPackages(decls []HandlerDecl) string {
+	dirs := map[string][]string{} // package name -> folders
+	for _, decl := range decls {
+		if !slices.Contains(dirs[decl.Package], decl.Dir) {
+			dirs[decl.Package] = append(dirs[decl.Package], decl.Dir)
+		}
+	}
+
+	handlersDir, _ := filepath.Abs("handlers")
+	imports := strings.Builder{}
+	aliases := map[string]string{} // folder -> alias
+	for name, folders := range dirs {
+		if len(folders) == 1 {
+			continue // imports.Process finds it by itself
+		}
+		for _, dir := range folders {
+			path, err := helpers.ImportPath(dir)
+			if err != nil {
+				log.Fatalf(pkg.Red+"Could not import %s: %v"+pkg.Reset, dir, err)
+			}
+			alias := name
+			if rel, err := filepath.Rel(handlersDir, dir); err == nil && rel != "." {
+				alias = notIdentifier.ReplaceAllString(rel, "")
+			}
+			aliases[dir] = alias
+			fmt.Fprintf(&imports, "%s %q\n", alias, path)
+		}
+	}
+
+	for i := range decls {
+		if alias, ok := aliases[decls[i].Dir]; ok {
+			decls[i].Package = alias
+		}
+	}
+	return imports.String()
+}
+
+// reservedImports lists the packages the router imports by itself, which the middlewares'
+// packages can't be named after: the template's and the handlers'.
+func reservedImports(decls []HandlerDecl) fileImports {
+	module, _ := helpers.ImportPath(".")
+	reserved := fileImports{
+		"context":     "context",
+		"fmt":         "fmt",
+		"log":         "log",
+		"time":        "time",
+		"http":        "net/http",
+		"spout":       "github.com/sh-lucas/mug/pkg/spout",
+		"brew":        brewPath,
+		"tracing":     "github.com/sh-lucas/mug/pkg/tracing",
+		"middlewares": module + "/middlewares",
+	}
+	for _, decl := range decls {
+		if path, err := helpers.ImportPath(decl.Dir); err == nil {
+			reserved[decl.Package] = path
+		}
+	}
+	return reserved
+}
+
+var notIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
 func ParseFunc(pkgName string, funcDecl *ast.FuncDecl, decls *[]HandlerDecl) {
 	// skips functions without comments
 	if funcDecl.Doc == nil || len(funcDecl.Doc.List) == 0 {
@@ -0,0 +1,181 @@
+package router
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sh-lucas/mug/pkg"
+)
+
+// middleware is a middleware of an annotation, resolved against the imports of its file.
+type middleware struct {
+	Path string // import path of its package, "" for the project's middlewares package
+	Name string // its package's name in the file
+	Expr string // what follows the package, e.g. Require("admin")
+}
+
+// fileImports maps the names a file gives its imports to their paths.
+type fileImports map[string]string
+
+// mugPackages are known without being imported, as in // > brew.Timeout.
+var mugPackages = fileImports{"brew": brewPath}
+
+const brewPath = "github.com/sh-lucas/mug/pkg/brew"
+
+// importsOf lists the named imports of file; blank and dot imports can't qualify a middleware.
+func importsOf(file *ast.File) fileImports {
+	imports := fileImports{}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importName(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name != "_" && name != "." {
+			imports[name] = path
+		}
+	}
+	return imports
+}
+
+// importName guesses the name of an unaliased import from its path, as goimports does:
+// its last element, skipping a major version, e.g. log for github.com/x/log/v2.
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if versionFolder.MatchString(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "-go"), "go-")
+	return notIdentifier.ReplaceAllString(name, "")
+}
+
+// parseMiddleware reads a middleware of an annotation written in a file with these imports.
+// Bare names come from the project's middlewares package, qualified ones from the package the
+// file imports under that name, and both may be called with arguments to build the middleware:
+//
+//	// > Cors > RateLimit(10, "1s") > authmw.Require("admin")
+func parseMiddleware(mw string, imports fileImports) middleware {
+	mw = strings.TrimSpace(mw)
+	expr, err := parser.ParseExpr(mw)
+	if err != nil {
+		log.Fatalf(pkg.Red+"Invalid middleware %q: %v"+pkg.Reset, mw, err)
+	}
+
+	fn := expr
+	if call, ok := expr.(*ast.CallExpr); ok {
+		fn = call.Fun
+	}
+	switch fn := fn.(type) {
+	case *ast.Ident:
+		return middleware{Expr: mw}
+	case *ast.SelectorExpr:
+		x, ok := fn.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		path, ok := imports[x.Name]
+		if !ok {
+			path, ok = mugPackages[x.Name]
+		}
+		if !ok {
+			log.Fatalf(pkg.Red+"Invalid middleware %q: its file doesn't import %s"+pkg.Reset, mw, x.Name)
+		}
+		// positions start at 1
+		return middleware{Path: path, Name: x.Name, Expr: mw[fn.Sel.Pos()-1:]}
+	}
+	log.Fatalf(pkg.Red+"Invalid middleware %q: expected Name, pkg.Name or a call to one of them"+pkg.Reset, mw)
+	return middleware{}
+}
+
+// routerImports names the packages of the middlewares in the router. A package keeps its name
+// in the handler's file unless the router already uses it for another one, e.g. spout or a
+// handler package, in which case it's numbered: auth, auth2...
+type routerImports struct {
+	paths map[string]string // name -> import path
+	added []string          // names of the imports to print
+}
+
+// newRouterImports reserves the names the router imports by itself, by their paths.
+func newRouterImports(reserved fileImports) *routerImports {
+	r := &routerImports{paths: map[string]string{}}
+	for name, path := range reserved {
+		r.paths[name] = path
+	}
+	return r
+}
+
+// expr prints mw, importing its package.
+func (r *routerImports) expr(mw middleware) string {
+	if mw.Path == "" {
+		return "middlewares." + mw.Expr
+	}
+	return r.name(mw.Path, mw.Name) + "." + mw.Expr
+}
+
+// name returns the name of the package at path, importing it under name if it's free.
+func (r *routerImports) name(path, name string) string {
+	for n, p := range r.paths {
+		if p == path {
+			return n
+		}
+	}
+	candidate := name
+	for n := 2; r.paths[candidate] != ""; n++ {
+		candidate = fmt.Sprintf("%s%d", name, n)
+	}
+	r.paths[candidate] = path
+	r.added = append(r.added, candidate)
+	return candidate
+}
+
+// String prints the added imports, sorted so the router doesn't change between runs.
+func (r *routerImports) String() string {
+	imports := strings.Builder{}
+	for _, name := range slices.Sorted(slices.Values(r.added)) {
+		fmt.Fprintf(&imports, "%s %q\n", name, r.paths[name])
+	}
+	return imports.String()
+}
+
+// splitMiddlewares splits a chain on the '>' outside of quotes and parentheses,
+// so arguments like RequireAge(x > 18) or Header("a>b") stay whole.
+func splitMiddlewares(chain string) (split []string) {
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range chain {
+		switch {
+		case quote != 0:
+			if r == quote && chain[i-1] != '\\' {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		case r == '>' && depth == 0:
+			split = append(split, chain[start:i])
+			start = i + 1
+		}
+	}
+	split = append(split, chain[start:])
+
+	// drops the blanks around and between the arrows
+	mws := split[:0]
+	for _, mw := range split {
+		if mw = strings.TrimSpace(mw); mw != "" {
+			mws = append(mws, mw)
+		}
+	}
+	return mws
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+)
+
+func TestSplitMiddlewares(t *testing.T) {
+	cases := map[string][]string{
+		" > Cors > FactLogging ":                  {"Cors", "FactLogging"},
+		`> Header("X-Brew", "espresso > latte")`:  {`Header("X-Brew", "espresso > latte")`},
+		"> RequireAge(x > 18) > Cors":             {"RequireAge(x > 18)", "Cors"},
+		"> Roles([]string{`a>b`}) > Cors":         {"Roles([]string{`a>b`})", "Cors"},
+		`> Header("a\"> b") >> Cors >`:            {`Header("a\"> b")`, "Cors"},
+		"":                                        nil,
+		"> authmw.Require(\"admin\") > brew.Gzip": {`authmw.Require("admin")`, "brew.Gzip"},
+	}
+	for chain, want := range cases {
+		if got := splitMiddlewares(chain); !slices.Equal(got, want) {
+			t.Errorf("splitMiddlewares(%q) = %q, want %q", chain, got, want)
+		}
+	}
+}
+
+const handlerFile = `package admin
+
+import (
+	"net/http"
+
+	authmw "example.com/app/auth"
+	"example.com/app/limits/v2"
+	"github.com/go-chi/httprate-go"
+	_ "example.com/app/plugins"
+)
+`
+
+func TestParseMiddleware(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "admin.go", handlerFile, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := importsOf(file)
+
+	cases := map[string]middleware{
+		"Cors":                    {Expr: "Cors"},
+		` RateLimit(10, "1s") `:   {Expr: `RateLimit(10, "1s")`},
+		`authmw.Require("admin")`: {Path: "example.com/app/auth", Name: "authmw", Expr: `Require("admin")`},
+		"limits.PerIP":            {Path: "example.com/app/limits/v2", Name: "limits", Expr: "PerIP"},
+		"httprate.Limit(5)":       {Path: "github.com/go-chi/httprate-go", Name: "httprate", Expr: "Limit(5)"},
+		"brew.BodyLimit":          {Path: brewPath, Name: "brew", Expr: "BodyLimit"},
+		"http.MaxBytesHandler":    {Path: "net/http", Name: "http", Expr: "MaxBytesHandler"},
+	}
+	for mw, want := range cases {
+		if got := parseMiddleware(mw, imports); got != want {
+			t.Errorf("parseMiddleware(%q) = %+v, want %+v", mw, got, want)
+		}
+	}
+	if _, ok := imports["plugins"]; ok {
+		t.Errorf("blank import usable by middlewares: %v", imports)
+	}
+}
+
+func TestRouterImports(t *testing.T) {
+	imports := newRouterImports(fileImports{"spout": "github.com/sh-lucas/mug/pkg/spout", "brew": brewPath})
+
+	// a package named like one of the router's is renamed, wherever it's used
+	auth := middleware{Path: "example.com/app/spout", Name: "spout", Expr: "Require"}
+	if got := imports.expr(auth); got != "spout2.Require" {
+		t.Errorf("clashing package printed as %q", got)
+	}
+	if got := imports.expr(middleware{Path: "example.com/app/spout", Name: "s", Expr: "Audit"}); got != "spout2.Audit" {
+		t.Errorf("package imported twice: %q", got)
+	}
+	// same name, other packages
+	if got := imports.expr(middleware{Path: "example.com/v2/auth", Name: "auth", Expr: "Require"}); got != "auth.Require" {
+		t.Errorf("free name not kept: %q", got)
+	}
+	if got := imports.expr(middleware{Path: "example.com/v3/auth", Name: "auth", Expr: "Require"}); got != "auth2.Require" {
+		t.Errorf("same-name packages not told apart: %q", got)
+	}
+	if got := imports.expr(middleware{Path: brewPath, Name: "brew", Expr: "Gzip"}); got != "brew.Gzip" {
+		t.Errorf("brew printed as %q", got)
+	}
+	if got := imports.expr(middleware{Expr: "Cors"}); got != "middlewares.Cors" {
+		t.Errorf("bare middleware printed as %q", got)
+	}
+
+	want := "auth \"example.com/v2/auth\"\nauth2 \"example.com/v3/auth\"\nspout2 \"example.com/app/spout\"\n"
+	if got := imports.String(); got != want {
+		t.Errorf("imports:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseGroup(t *testing.T) {
+	imports := fileImports{"authmw": "example.com/app/auth"}
+	prefix, mws := parseGroup("// mug:group /admin/ > Cors > authmw.Require(\"admin\")", `/admin/ > Cors > authmw.Require("admin")`, imports)
+	if prefix != "/admin" {
+		t.Errorf("prefix = %q", prefix)
+	}
+	want := []middleware{{Expr: "Cors"}, {Path: "example.com/app/auth", Name: "authmw", Expr: `Require("admin")`}}
+	if !slices.Equal(mws, want) {
+		t.Errorf("middlewares = %+v, want %+v", mws, want)
+	}
+}
@@ -5,13 +5,29 @@ import (
 	"fmt"
 	"go/ast"
 	"log"
+	"slices"
 	"strings"
 
+	"github.com/sh-lucas/mug/internal/config"
 	"github.com/sh-lucas/mug/pkg"
 )
 
-func printBasicRouter(w *strings.Builder, path string, handler HandlerDecl) {
-	fmt.Fprintf(w, "router.HandleFunc(\"%s\", %s.%s)\n", path, handler.Package, handler.Fn.Name.Name)
+func printBasicRouter(w *strings.Builder, imports *routerImports, path string, handler HandlerDecl) {
+	if !parseDoc(handler.Doc).Require.empty() {
+		log.Fatalf(pkg.Red+"%s can't use // mug:require: only handlers with a struct input and an auth mixin are authorized"+pkg.Reset, handler.Fn.Name.Name)
+	}
+	mws := middlewaresOf(handler)
+	version := handler.Folder.Version
+
+	// middlewares wrap the handler, the first one being the outermost
+	wrapped := fmt.Sprintf("http.HandlerFunc(%s.%s)", handler.Package, handler.Fn.Name.Name)
+	for i := len(mws) - 1; i >= 0; i-- {
+		wrapped = fmt.Sprintf("%s(%s)", imports.expr(mws[i]), wrapped)
+	}
+	if version != nil {
+		wrapped = fmt.Sprintf("spout.Versioned(%q, %s)", version.Name, wrapped)
+	}
+	fmt.Fprintf(w, "spout.Handle(router, \"%s\", %s)\n", path, wrapped)
 }
 
 type InjectRouterValues struct {
@@ -23,7 +39,7 @@ type InjectRouterValues struct {
 
 var injectRouterSyntaxTmpl = pkg.Red + "Function %s needs to return (int, any), being 'any' the returned body after json marshalling" + pkg.Reset
 
-func printInjectRouter(w *strings.Builder, path string, handler HandlerDecl) {
+func printInjectRouter(w *strings.Builder, imports *routerImports, path string, handler HandlerDecl) {
 	// type checks
 	results := handler.Fn.Type.Results.List
 	identCode, frst := results[0].Type.(*ast.Ident)
@@ -32,22 +48,43 @@ func printInjectRouter(w *strings.Builder, path string, handler HandlerDecl) {
 		log.Fatalf(injectRouterSyntaxTmpl, handler.Fn.Name)
 	}
 
-	// if the last comment contains middleware names, append as last arg
+	// the group's and the handler's middlewares, appended as last args
 	mws := strings.Builder{}
-	for _, mw := range getMiddlewares(handler.Doc) {
-		fmt.Fprintf(&mws, "middlewares.%s, ", mw)
+	for _, mw := range middlewaresOf(handler) {
+		fmt.Fprintf(&mws, "%s, ", imports.expr(mw))
 	}
 
 	// code generated route path =)
 	fmt.Fprintf(w, "fmt.Println(\"[%s] %s\")\n", handler.Fn.Name.Name, path)
 
 	// code generated new router =)
+	doc := parseDoc(handler.Doc)
+	doc.loadExamples(handler.Dir, handler.Fn.Name.Name)
+	if handler.Folder.Version != nil {
+		doc.Version = handler.Folder.Version.Name
+	}
+	if doc.empty() {
+		fmt.Fprintf(
+			w, "spout.MakeHandler(router, \"%s\", %s.%s, %s)\n",
+			path, handler.Package, handler.Fn.Name, mws.String(),
+		)
+		return
+	}
 	fmt.Fprintf(
-		w, "spout.MakeHandler(router, \"%s\", %s.%s, %s)\n",
-		path, handler.Package, handler.Fn.Name, mws.String(),
+		w, "spout.MakeRoute(router, \"%s\", %s, %s.%s, %s)\n",
+		path, doc.literal(), handler.Package, handler.Fn.Name, mws.String(),
 	)
 }
 
+// middlewaresOf lists the middlewares of a handler: its groups' first, then its own.
+func middlewaresOf(handler HandlerDecl) (mws []middleware) {
+	mws = append(mws, handler.Folder.Middlewares...)
+	for _, mw := range getMiddlewares(handler.Doc) {
+		mws = append(mws, parseMiddleware(mw, handler.Imports))
+	}
+	return mws
+}
+
 func getMiddlewares(comment *ast.CommentGroup) []string {
 	comments := comment.List
 	last := comments[len(comments)-1].Text
@@ -55,7 +92,77 @@ func getMiddlewares(comment *ast.CommentGroup) []string {
 	if strings.HasPrefix(last, "// > ") || strings.HasPrefix(last, "//> ") {
 		last = strings.TrimPrefix(last, "// >")
 		last = strings.TrimPrefix(last, "//>")
-		return strings.Split(last, " > ")
+		return splitMiddlewares(last)
 	}
 	return []string{}
 }
+
+// docsConfigLiteral prints the openapi section of mug.yml as a spout.DocsConfig literal.
+func docsConfigLiteral() string {
+	cfg := config.Global.OpenAPI
+	servers := strings.Builder{}
+	for _, server := range cfg.Servers {
+		fmt.Fprintf(&servers, "{URL: %q, Description: %q}, ", server.URL, server.Description)
+	}
+	return fmt.Sprintf(
+		"spout.DocsConfig{Title: %q, Version: %q, Renderer: %q, AssetsDir: %q, Servers: []spout.Server{%s}}",
+		cfg.Title, cfg.Version, cfg.Renderer, cfg.Assets, servers.String(),
+	)
+}
+
+// brewMiddlewares are the names of brew.Middlewares, sorted. They're listed here
+// so the CLI doesn't link brew, and everything it imports, for a list of names.
+var brewMiddlewares = []string{
+	"AccessLog", "BodyLimit", "CORS", "Compress", "RealIP", "Recover", "RequestID", "SecureHeaders", "Timeout",
+}
+
+// brewConfigLiteral prints the brew section of mug.yml as a brew.Config literal,
+// or "" if neither the handlers nor the global middlewares use brew.
+func brewConfigLiteral(decls []HandlerDecl) string {
+	cfg := config.Global.Brew
+	for _, name := range cfg.Global {
+		if !slices.Contains(brewMiddlewares, name) {
+			log.Fatalf(pkg.Red+"Unknown global middleware %q in mug.yml, brew has: %s"+pkg.Reset,
+				name, strings.Join(brewMiddlewares, ", "))
+		}
+	}
+
+	used := len(cfg.Global) > 0 || slices.ContainsFunc(decls, func(handler HandlerDecl) bool {
+		return slices.ContainsFunc(middlewaresOf(handler), func(mw middleware) bool {
+			return mw.Path == brewPath
+		})
+	})
+	if !used {
+		return ""
+	}
+	sh := cfg.SecureHeaders
+	return fmt.Sprintf("brew.Config{Global: %#v, "+
+		"RequestID: brew.RequestIDConfig{Header: %q}, "+
+		"AccessLog: brew.AccessLogConfig{Skip: %#v}, "+
+		"Recover: brew.RecoverConfig{Stack: %t}, "+
+		"CORS: brew.CORSConfig{AllowedOrigins: %#v, AllowedMethods: %#v, AllowedHeaders: %#v, ExposedHeaders: %#v, AllowCredentials: %t, MaxAge: %d}, "+
+		"Compress: brew.CompressConfig{Encodings: %#v, MinSize: %d, Types: %#v}, "+
+		"Timeout: %d, BodyLimit: %d, "+
+		"RealIP: brew.RealIPConfig{Headers: %#v, TrustedProxies: %#v}, "+
+		"SecureHeaders: brew.SecureHeadersConfig{ContentTypeOptions: %q, FrameOptions: %q, ReferrerPolicy: %q, StrictTransportSecurity: %q, ContentSecurityPolicy: %q, PermissionsPolicy: %q}}",
+		cfg.Global,
+		cfg.RequestID.Header,
+		cfg.AccessLog.Skip,
+		cfg.Recover.Stack,
+		cfg.CORS.AllowedOrigins, cfg.CORS.AllowedMethods, cfg.CORS.AllowedHeaders, cfg.CORS.ExposedHeaders, cfg.CORS.AllowCredentials, cfg.CORS.MaxAge,
+		cfg.Compress.Encodings, cfg.Compress.MinSize, cfg.Compress.Types,
+		cfg.Timeout, cfg.BodyLimit,
+		cfg.RealIP.Headers, cfg.RealIP.TrustedProxies,
+		sh.ContentTypeOptions, sh.FrameOptions, sh.ReferrerPolicy, sh.StrictTransportSecurity, sh.ContentSecurityPolicy, sh.PermissionsPolicy,
+	)
+}
+
+// serverConfigLiteral prints the server section of mug.yml as a spout.ServerConfig literal.
+func serverConfigLiteral() string {
+	cfg := config.Global.Server
+	return fmt.Sprintf("spout.ServerConfig{ReadTimeout: %d, ReadHeaderTimeout: %d, WriteTimeout: %d, IdleTimeout: %d, "+
+		"ShutdownDelay: %d, ShutdownTimeout: %d, CertFile: %q, KeyFile: %q, Socket: %q}",
+		cfg.ReadTimeout, cfg.ReadHeaderTimeout, cfg.WriteTimeout, cfg.IdleTimeout,
+		cfg.ShutdownDelay, cfg.ShutdownTimeout, cfg.CertFile, cfg.KeyFile, cfg.Socket,
+	)
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/sh-lucas/mug/internal/config"
+	"github.com/sh-lucas/mug/pkg/brew"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+func TestBrewMiddlewares(t *testing.T) {
+	if names := slices.Sorted(maps.Keys(brew.Middlewares)); !slices.Equal(names, brewMiddlewares) {
+		t.Errorf("brewMiddlewares = %q, brew has %q", brewMiddlewares, names)
+	}
+}
+
+// the sections of mug.yml are printed field by field, so they must keep up with the library
+func TestConfigSections(t *testing.T) {
+	sameFields(t, reflect.TypeFor[config.Brew](), reflect.TypeFor[brew.Config]())
+	sameFields(t, reflect.TypeFor[config.Server](), reflect.TypeFor[spout.ServerConfig]())
+}
+
+func sameFields(t *testing.T, section, lib reflect.Type) {
+	t.Helper()
+	if section.NumField() != lib.NumField() {
+		t.Errorf("%s has %d fields, %s has %d", section, section.NumField(), lib, lib.NumField())
+		return
+	}
+	for i := range lib.NumField() {
+		got, want := section.Field(i), lib.Field(i)
+		if got.Name != want.Name || got.Tag.Get("yaml") != want.Tag.Get("yaml") || got.Type.Kind() != want.Type.Kind() {
+			t.Errorf("%s.%s (%s) doesn't match %s.%s (%s)", section, got.Name, got.Type, lib, want.Name, want.Type)
+			continue
+		}
+		if want.Type.Kind() == reflect.Struct {
+			sameFields(t, got.Type, want.Type)
+		}
+	}
+}
@@ -2,16 +2,19 @@ package helpers
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/sh-lucas/mug/internal/config"
+	"github.com/sh-lucas/mug/pkg"
 )
 
+var logger = slog.New(pkg.NewPrettyHandler(os.Stderr, slog.LevelDebug))
+
+// Logf logs a debug message of the CLI, only shown when debug is enabled in mug.yml.
 func Logf(pattern string, params ...any) {
-	if pattern[len(pattern)-1] != '\n' {
-		pattern = fmt.Sprintf("%s\n", pattern)
-	}
 	if config.Global.Debug {
-		log.Printf(pattern, params...)
+		logger.Debug(strings.TrimSuffix(fmt.Sprintf(pattern, params...), "\n"))
 	}
 }
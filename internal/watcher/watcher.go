@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/sh-lucas/mug/internal/config"
 	"github.com/sh-lucas/mug/internal/helpers"
 	"github.com/sh-lucas/mug/pkg"
 )
@@ -125,8 +126,18 @@ func waiter(task Task) {
 	}
 }
 
+// minGracePeriod is the patience of Kill for servers shutting down at once.
+const minGracePeriod = 3 * time.Second
+
+// gracePeriod is how long the server may take to stop after SIGTERM:
+// the shutdown delay and timeout of mug.yml, plus a little to exit.
+func gracePeriod() time.Duration {
+	server := config.Global.Server
+	return max(minGracePeriod, server.ShutdownDelay+server.ShutdownTimeout+time.Second)
+}
+
 // gracefully stop the running process
-// it's patience only lasts for 3 seconds
+// it's patience lasts as long as the server's shutdown
 func Kill() {
 	if running != nil && running.Process != nil {
 		err := syscall.Kill(-running.Process.Pid, syscall.SIGTERM)
@@ -140,7 +151,7 @@ func Kill() {
 		}()
 
 		select {
-		case <-time.After(3 * time.Second):
+		case <-time.After(gracePeriod()):
 			log.Println("Process did not exit in time, killing it forcefully")
 			err = syscall.Kill(-running.Process.Pid, syscall.SIGKILL)
 			if err != nil {
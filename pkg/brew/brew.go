@@ -0,0 +1,133 @@
+// Package brew has the middlewares every API ends up writing, ready for the `// >` annotations:
+//
+//	// mug:handler POST /orders
+//	// > brew.BodyLimit > brew.Timeout
+//
+// They are configured by the brew section of mug.yml, which the generated router
+// passes to Configure. Global middlewares wrap the whole router instead of a single route.
+package brew
+
+import (
+	"net/http"
+	"time"
+)
+
+type Middleware = func(http.Handler) http.Handler
+
+// Config sets up every middleware of brew; empty values disable the matching feature.
+type Config struct {
+	Global        []string            `yaml:"global"` // names of the middlewares wrapping the whole router, outermost first
+	RequestID     RequestIDConfig     `yaml:"request_id"`
+	AccessLog     AccessLogConfig     `yaml:"access_log"`
+	Recover       RecoverConfig       `yaml:"recover"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Compress      CompressConfig      `yaml:"compress"`
+	Timeout       time.Duration       `yaml:"timeout"`
+	BodyLimit     int64               `yaml:"body_limit"` // in bytes
+	RealIP        RealIPConfig        `yaml:"real_ip"`
+	SecureHeaders SecureHeadersConfig `yaml:"secure_headers"`
+}
+
+type RequestIDConfig struct {
+	Header string `yaml:"header"`
+}
+
+type AccessLogConfig struct {
+	Skip []string `yaml:"skip"` // paths not logged, like health checks
+}
+
+type RecoverConfig struct {
+	Stack bool `yaml:"stack"` // log the stack trace of panics
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"` // "*" allows any
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+type CompressConfig struct {
+	Encodings []string `yaml:"encodings"` // "br" and "gzip", by preference
+	MinSize   int      `yaml:"min_size"`  // smaller responses are sent as they are
+	Types     []string `yaml:"types"`     // compressed content types; a trailing * matches any subtype
+}
+
+type RealIPConfig struct {
+	Headers        []string `yaml:"headers"`         // read in order, e.g. X-Forwarded-For
+	TrustedProxies []string `yaml:"trusted_proxies"` // CIDRs allowed to set the headers
+}
+
+type SecureHeadersConfig struct {
+	ContentTypeOptions      string `yaml:"content_type_options"`
+	FrameOptions            string `yaml:"frame_options"`
+	ReferrerPolicy          string `yaml:"referrer_policy"`
+	StrictTransportSecurity string `yaml:"strict_transport_security"`
+	ContentSecurityPolicy   string `yaml:"content_security_policy"`
+	PermissionsPolicy       string `yaml:"permissions_policy"`
+}
+
+// Defaults are the settings used until Configure is called; mug.yml starts with the same ones.
+var Defaults = Config{
+	RequestID: RequestIDConfig{Header: "X-Request-ID"},
+	Recover:   RecoverConfig{Stack: true},
+	CORS: CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	},
+	Compress: CompressConfig{
+		Encodings: []string{"br", "gzip"},
+		MinSize:   1024,
+		Types:     []string{"application/json", "application/javascript", "image/svg+xml", "text/*"},
+	},
+	Timeout:   30 * time.Second,
+	BodyLimit: 1 << 20,
+	RealIP: RealIPConfig{
+		Headers:        []string{"X-Forwarded-For", "X-Real-IP"},
+		TrustedProxies: []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+	},
+	SecureHeaders: SecureHeadersConfig{
+		ContentTypeOptions:      "nosniff",
+		FrameOptions:            "DENY",
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		StrictTransportSecurity: "max-age=63072000; includeSubDomains",
+	},
+}
+
+// Settings are the current settings. Middlewares read them when wrapping a handler.
+var Settings = Defaults
+
+// Configure replaces the settings; call it before registering the routes.
+func Configure(c Config) {
+	Settings = c
+	trusted = parseCIDRs(c.RealIP.TrustedProxies)
+}
+
+// Middlewares are brew's middlewares by name, as listed in Config.Global.
+var Middlewares = map[string]Middleware{
+	"RequestID":     RequestID,
+	"AccessLog":     AccessLog,
+	"Recover":       Recover,
+	"CORS":          CORS,
+	"Compress":      Compress,
+	"Timeout":       Timeout,
+	"BodyLimit":     BodyLimit,
+	"RealIP":        RealIP,
+	"SecureHeaders": SecureHeaders,
+}
+
+// Wrap wraps h with the global middlewares, the first one being the outermost.
+// Some only work globally: CORS has to answer preflight requests,
+// which never reach a route registered for another method.
+func Wrap(h http.Handler) http.Handler {
+	for i := len(Settings.Global) - 1; i >= 0; i-- {
+		if mw, ok := Middlewares[Settings.Global[i]]; ok {
+			h = mw(h)
+		}
+	}
+	return h
+}
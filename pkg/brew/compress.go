@@ -0,0 +1,164 @@
+package brew
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compress compresses the responses with the first of Settings.Compress.Encodings
+// accepted by the client. Small responses and types not listed are sent as they are.
+func Compress(next http.Handler) http.Handler {
+	cfg := Settings.Compress
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiate(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate picks the first of the encodings the client accepts, "" if none.
+func negotiate(acceptEncoding string, encodings []string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(value, 64)
+		}
+		quality[strings.ToLower(name)] = q
+	}
+
+	for _, encoding := range encodings {
+		q, ok := quality[encoding]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > 0 {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter holds the response until it's big enough to be worth compressing
+// (or it ends), then decides whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      CompressConfig
+	encoding string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser // nil when sent as it is
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if !c.decided {
+		c.status = code
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.decided {
+		return c.out().Write(b)
+	}
+	c.buf.Write(b)
+	if c.buf.Len() >= c.cfg.MinSize {
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what's held, compressed if possible, for streamed responses.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		_ = c.decide(true)
+	}
+	if f, ok := c.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if err := c.decide(c.buf.Len() >= c.cfg.MinSize); err != nil {
+			return err
+		}
+	}
+	if c.encoder != nil {
+		return c.encoder.Close()
+	}
+	return nil
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// decide writes the header and what's held, compressing if big enough and allowed.
+func (c *compressWriter) decide(bigEnough bool) error {
+	c.decided = true
+	h := c.Header()
+	if h.Get("Content-Type") == "" && c.buf.Len() > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf.Bytes()))
+	}
+
+	compress := bigEnough && h.Get("Content-Encoding") == "" &&
+		c.status != http.StatusNoContent && c.status != http.StatusNotModified &&
+		c.compressible(h.Get("Content-Type"))
+	if compress {
+		switch c.encoding {
+		case "br":
+			c.encoder = brotli.NewWriter(c.ResponseWriter)
+		case "gzip":
+			c.encoder = gzip.NewWriter(c.ResponseWriter)
+		}
+	}
+	if c.encoder != nil {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", c.encoding)
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	_, err := c.out().Write(c.buf.Bytes())
+	c.buf.Reset()
+	return err
+}
+
+func (c *compressWriter) out() io.Writer {
+	if c.encoder != nil {
+		return c.encoder
+	}
+	return c.ResponseWriter
+}
+
+func (c *compressWriter) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.cfg.Types {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(mediaType, prefix) || t == mediaType {
+			return true
+		}
+	}
+	return false
+}
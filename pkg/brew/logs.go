@@ -0,0 +1,133 @@
+package brew
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg"
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+// logs is behind an atomic pointer, since middlewares read it concurrently.
+var logs atomic.Pointer[slog.Logger]
+
+func init() {
+	logs.Store(pkg.NewLogger(os.Stderr))
+}
+
+func logger() *slog.Logger {
+	return logs.Load()
+}
+
+// SetLogger replaces the logger of AccessLog and Recover.
+func SetLogger(l *slog.Logger) {
+	logs.Store(l)
+}
+
+type accessLogKey struct{}
+
+// AccessLog logs a structured line for every request once it's answered,
+// with its route pattern, status, size, latency and request ID.
+func AccessLog(next http.Handler) http.Handler {
+	skip := Settings.AccessLog.Skip
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, true))
+		if slices.Contains(skip, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		logger().LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("pattern", r.Pattern),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("request_id", RequestIDFrom(r.Context())),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// AccessLogged reports whether AccessLog is in charge of logging the request,
+// including the paths it skips, so the handlers don't log it again.
+func AccessLogged(ctx context.Context) bool {
+	return ctx.Value(accessLogKey{}) != nil
+}
+
+var internalErrorPayload = `{
+	"error": "Internal server error",
+	"message": "The issue must be reported to the system administrator."
+}`
+
+// Recover answers 500 when the handler panics, logging the panic and its stack trace.
+func Recover(next http.Handler) http.Handler {
+	stack := Settings.Recover.Stack
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // the response is aborted on purpose
+			}
+
+			attrs := []slog.Attr{
+				slog.String("panic", fmt.Sprint(err)),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("request_id", RequestIDFrom(r.Context())),
+			}
+			if stack {
+				attrs = append(attrs, slog.String("stack", string(debug.Stack())))
+			}
+			logger().LogAttrs(r.Context(), slog.LevelError, "panic recovered", attrs...)
+			mug.Error(w, internalErrorPayload, http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recorder keeps the status and size of a response.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the original writer, to flush it for example.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
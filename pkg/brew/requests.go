@@ -0,0 +1,168 @@
+package brew
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+type requestIDKey struct{}
+
+// RequestID gives every request an ID, reusing the one sent by the client or a proxy
+// if it looks sane. It's answered in the same header and available with RequestIDFrom.
+func RequestID(next http.Handler) http.Handler {
+	header := Settings.RequestID.Header
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom returns the ID given to the request by RequestID, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+var timeoutPayload = `{
+	"error": "timeout",
+	"message": "The request took too long to be answered."
+}`
+
+// Timeout answers 503 when the handler takes longer than Settings.Timeout,
+// canceling the request's context. The response is buffered until then.
+func Timeout(next http.Handler) http.Handler {
+	if Settings.Timeout <= 0 {
+		return next
+	}
+	timeout := http.TimeoutHandler(next, Settings.Timeout, timeoutPayload)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout.ServeHTTP(jsonOnTimeout{w}, r)
+	})
+}
+
+// jsonOnTimeout labels the timeout payload written by http.TimeoutHandler as json.
+type jsonOnTimeout struct {
+	http.ResponseWriter
+}
+
+func (w jsonOnTimeout) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+var tooLargePayload = `{
+	"error": "request too large",
+	"message": "The request body is larger than allowed."
+}`
+
+// BodyLimit rejects bodies bigger than Settings.BodyLimit bytes with 413,
+// and stops reading the ones that lie about their size.
+func BodyLimit(next http.Handler) http.Handler {
+	limit := Settings.BodyLimit
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			mug.Error(w, tooLargePayload, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+var trusted = parseCIDRs(Defaults.RealIP.TrustedProxies)
+
+// RealIP sets r.RemoteAddr to the client's IP, as told by the proxies in front of the app.
+// The headers are only believed when the request comes from a trusted proxy,
+// otherwise anyone could pretend to be anyone.
+func RealIP(next http.Handler) http.Handler {
+	headers := Settings.RealIP.Headers
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := realIP(r, headers); ip != "" {
+			r.RemoteAddr = ip
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func realIP(r *http.Request, headers []string) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !isTrusted(peer) {
+		return ""
+	}
+
+	for _, header := range headers {
+		// X-Forwarded-For is appended by each proxy: the client
+		// is the last address not added by a trusted one
+		var hops []string
+		for _, value := range r.Header.Values(header) {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		client := ""
+		for _, hop := range slices.Backward(hops) {
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			client = hop
+			if !isTrusted(hop) {
+				break
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+	return ""
+}
+
+func isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && slices.ContainsFunc(trusted, func(n *net.IPNet) bool { return n.Contains(parsed) })
+}
+
+func parseCIDRs(cidrs []string) (nets []*net.IPNet) {
+	for _, cidr := range cidrs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
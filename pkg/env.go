@@ -0,0 +1,9 @@
+package pkg
+
+import "os"
+
+// Dev reports whether the app runs in development.
+// `mug` sets MUG_ENV=dev on the apps it runs; anything else is production.
+func Dev() bool {
+	return os.Getenv("MUG_ENV") == "dev"
+}
@@ -0,0 +1,168 @@
+// Package health keeps the named checks served by spout at /healthz and /readyz.
+// Packages like rabbit register their own; add yours for databases, caches and so on.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// DefaultTimeout bounds the checks registered without a timeout.
+var DefaultTimeout = 2 * time.Second
+
+// Check is a named probe of something the app depends on.
+type Check struct {
+	Name string
+	// nil when healthy; it should give up when ctx is done
+	Func    func(ctx context.Context) error
+	Timeout time.Duration // DefaultTimeout if 0
+	// liveness checks also run on /healthz, failing it restarts the app;
+	// the others only run on /readyz, failing it only stops its traffic
+	Liveness bool
+}
+
+var registry = struct {
+	sync.Mutex
+	checks []Check
+}{}
+
+var shuttingDown atomic.Bool
+
+// Register adds check, replacing the one with the same name.
+func Register(check Check) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.checks = slices.DeleteFunc(registry.checks, func(c Check) bool { return c.Name == check.Name })
+	registry.checks = append(registry.checks, check)
+}
+
+// Unregister removes the check with name.
+func Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.checks = slices.DeleteFunc(registry.checks, func(c Check) bool { return c.Name == name })
+}
+
+// MarkShuttingDown makes readiness fail from now on, so load balancers stop sending traffic.
+// spout.Serve calls it when asked to stop.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// MarkServing undoes MarkShuttingDown, as a new server starts; spout.Serve calls it first.
+func MarkServing() {
+	shuttingDown.Store(false)
+}
+
+// ShuttingDown reports whether MarkShuttingDown was called.
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// Status values of Report and Result.
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusTimeout      = "timeout"
+	StatusShuttingDown = "shutting down"
+)
+
+// Report is the body of /healthz and /readyz.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Run runs the liveness checks, or all of them for readiness, at the same time.
+func Run(ctx context.Context, readiness bool) Report {
+	registry.Lock()
+	checks := slices.DeleteFunc(slices.Clone(registry.checks), func(c Check) bool { return !readiness && !c.Liveness })
+	registry.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if readiness && ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run runs check within its timeout; a check that doesn't give up in time is left behind.
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New("check panicked")
+			}
+		}()
+		done <- check.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Status, result.Error = StatusTimeout, "no answer within "+timeout.String()
+	case err != nil:
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+	return result
+}
+
+// Handler answers the Report of the liveness or readiness checks, with 503 when they don't pass.
+func Handler(readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), readiness)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		jsoniter.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// NewLogger returns the logger mug's packages use until they're given another one:
+// json lines in production, colored and readable ones in development (see Dev).
+func NewLogger(w io.Writer) *slog.Logger {
+	if Dev() {
+		return slog.New(NewPrettyHandler(w, slog.LevelDebug))
+	}
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// PrettyHandler writes records as colored lines made for humans:
+//
+//	15:04:05 INFO request method=GET status=200 latency=1.2ms
+type PrettyHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+	attrs string // preformatted attrs of WithAttrs
+	group string // prefix of the attrs keys, from WithGroup
+}
+
+func NewPrettyHandler(w io.Writer, level slog.Leveler) *PrettyHandler {
+	return &PrettyHandler{w: w, mu: &sync.Mutex{}, level: level}
+}
+
+func (h *PrettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *PrettyHandler) Handle(_ context.Context, record slog.Record) error {
+	color := Cyan
+	switch {
+	case record.Level >= slog.LevelError:
+		color = BoldRed
+	case record.Level >= slog.LevelWarn:
+		color = Yellow
+	case record.Level < slog.LevelInfo:
+		color = Purple
+	}
+
+	line := &strings.Builder{}
+	if !record.Time.IsZero() {
+		line.WriteString(record.Time.Format("15:04:05 "))
+	}
+	fmt.Fprintf(line, "%s%-5s%s %s", color, record.Level, Reset, record.Message)
+	line.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		writeAttr(line, h.group, attr)
+		return true
+	})
+	line.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	line := &strings.Builder{}
+	for _, attr := range attrs {
+		writeAttr(line, h.group, attr)
+	}
+	clone := *h
+	clone.attrs += line.String()
+	return &clone
+}
+
+func (h *PrettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group += name + "."
+	return &clone
+}
+
+func writeAttr(line *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, inner := range attr.Value.Group() {
+			writeAttr(line, prefix, inner)
+		}
+		return
+	}
+
+	value := attr.Value.String()
+	// multiline values, like stack traces, go below the line
+	if strings.Contains(value, "\n") {
+		fmt.Fprintf(line, "\n%s%s%s:\n%s", Blue, group+attr.Key, Reset, strings.TrimRight(value, "\n"))
+		return
+	}
+	if value == "" || strings.ContainsAny(value, " \"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(line, " %s%s=%s%s", Blue, group+attr.Key, Reset, value)
+}
@@ -0,0 +1,158 @@
+package mug
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// where APIKeyAuth reads the key from; set APIKeyQuery to "" to only accept the header
+var (
+	APIKeyHeader = "X-API-Key"
+	APIKeyQuery  = "api_key"
+)
+
+// ErrUnknownKey is returned by key stores when no one owns the key.
+var ErrUnknownKey = errors.New("unknown API key")
+
+// KeyStore finds the principal owning an API key, e.g. a service account in a database.
+type KeyStore[T any] interface {
+	Lookup(ctx context.Context, key string) (T, error)
+}
+
+// KeyStoreFunc adapts a function to a KeyStore.
+type KeyStoreFunc[T any] func(ctx context.Context, key string) (T, error)
+
+func (f KeyStoreFunc[T]) Lookup(ctx context.Context, key string) (T, error) {
+	return f(ctx, key)
+}
+
+// the store of each principal type, see SetKeyStore
+var keyStores sync.Map
+
+// SetKeyStore makes APIKeyAuth[T] look keys up in store.
+func SetKeyStore[T any](store KeyStore[T]) {
+	keyStores.Store(reflect.TypeFor[T](), store)
+}
+
+func init() {
+	SetKeyStore(EnvKeyStore("MUG_API_KEYS"))
+}
+
+// KeyOwner is the principal of EnvKeyStore: the name the key is listed under.
+type KeyOwner struct {
+	Name string `json:"name"`
+}
+
+// HashAPIKey is the hex SHA-256 of key, how EnvKeyStore compares keys.
+// Store it instead of the key itself.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnvKeyStore reads the keys from env, a comma separated list of name:key pairs.
+// A key written as sha256:<hex> is its HashAPIKey, so the env holds no secret:
+//
+//	MUG_API_KEYS=billing:sha256:9f86d08...,reports:s3cr3t
+//
+// The env is read on each lookup, and every key is compared, in constant time.
+func EnvKeyStore(env string) KeyStore[KeyOwner] {
+	return KeyStoreFunc[KeyOwner](func(_ context.Context, key string) (KeyOwner, error) {
+		hash := sha256.Sum256([]byte(key))
+		owner, found := KeyOwner{}, false
+
+		for _, entry := range strings.Split(os.Getenv(env), ",") {
+			name, stored, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				continue
+			}
+			if hexHash, ok := strings.CutPrefix(stored, "sha256:"); ok {
+				stored = hexHash
+			} else {
+				stored = HashAPIKey(stored)
+			}
+			want, err := hex.DecodeString(stored)
+			if err != nil {
+				continue
+			}
+			if subtle.ConstantTimeCompare(hash[:], want) == 1 && !found {
+				owner, found = KeyOwner{Name: name}, true
+			}
+		}
+
+		if !found {
+			return KeyOwner{}, ErrUnknownKey
+		}
+		return owner, nil
+	})
+}
+
+// APIKey is a convenience alias for APIKeyAuth with the keys of MUG_API_KEYS
+type APIKey = APIKeyAuth[KeyOwner]
+
+// APIKeyAuth mixin for API key authentication, filling the principal owning the key.
+// Keys are looked up in the store set for T with SetKeyStore.
+type APIKeyAuth[T any] struct {
+	Principal T `json:"-"`
+}
+
+func (a *APIKeyAuth[T]) ErrorResponses() map[int]string {
+	return map[int]string{
+		http.StatusUnauthorized: "Missing or invalid API key",
+	}
+}
+
+func (a *APIKeyAuth[T]) SecuritySchemes() []SecurityScheme {
+	schemes := []SecurityScheme{{
+		Name:      "apiKeyHeader",
+		Type:      "apiKey",
+		In:        "header",
+		ParamName: APIKeyHeader,
+	}}
+	if APIKeyQuery != "" {
+		schemes = append(schemes, SecurityScheme{
+			Name:      "apiKeyQuery",
+			Type:      "apiKey",
+			In:        "query",
+			ParamName: APIKeyQuery,
+		})
+	}
+	return schemes
+}
+
+func (a *APIKeyAuth[T]) Authenticate(w http.ResponseWriter, r *http.Request) bool {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" && APIKeyQuery != "" {
+		key = r.URL.Query().Get(APIKeyQuery)
+	}
+	if key == "" {
+		Error(w, fmt.Sprintf(missingKeyPayload, APIKeyHeader), http.StatusUnauthorized)
+		return false
+	}
+
+	stored, ok := keyStores.Load(reflect.TypeFor[T]())
+	if !ok {
+		// a programming error: spout logs it and answers 500
+		panic(fmt.Sprintf("mug: no KeyStore set for %s, see mug.SetKeyStore", reflect.TypeFor[T]()))
+	}
+	principal, err := stored.(KeyStore[T]).Lookup(r.Context(), key)
+	if errors.Is(err, ErrUnknownKey) {
+		Error(w, invalidKeyPayload, http.StatusUnauthorized)
+		return false
+	} else if err != nil {
+		Error(w, internalErrorPayload, http.StatusInternalServerError)
+		return false
+	}
+
+	a.Principal = principal
+	return true
+}
@@ -0,0 +1,111 @@
+package mug
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Permissions are the roles and scopes of who authenticated,
+// checked against the // mug:require annotation of the route.
+type Permissions struct {
+	Roles  []string
+	Scopes []string
+}
+
+// Permissioned is implemented by auth mixins, and by claims or principals that know their own permissions.
+type Permissioned interface {
+	Permissions() Permissions
+}
+
+// Authorizer is implemented by claims and principals deciding whether they may call the route;
+// an error answers 403 with its message.
+type Authorizer interface {
+	Authorize(r *http.Request) error
+}
+
+// Permissions of the token: the claims' own if they're Permissioned, or else
+// its "roles" claim and its "scope" (space separated) or "scp" claim.
+func (b *BearerAuth[T]) Permissions() Permissions {
+	if p, ok := any(&b.Claims).(Permissioned); ok {
+		return p.Permissions()
+	}
+	return b.permissions
+}
+
+// tokenPermissions reads the permissions of a verified token from its payload,
+// so they're found even when the claims type drops them, like jwt.RegisteredClaims.
+func tokenPermissions(token string) Permissions {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Permissions{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return Permissions{}
+	}
+
+	var claims struct {
+		Roles stringList `json:"roles"`
+		Scope string     `json:"scope"`
+		Scp   stringList `json:"scp"`
+	}
+	_ = jsoniter.Unmarshal(payload, &claims)
+	return Permissions{Roles: claims.Roles, Scopes: append(strings.Fields(claims.Scope), claims.Scp...)}
+}
+
+func (b *BearerAuth[T]) Authorize(r *http.Request) error {
+	if a, ok := any(&b.Claims).(Authorizer); ok {
+		return a.Authorize(r)
+	}
+	return nil
+}
+
+// Permissions of the key's principal, none unless it's Permissioned.
+func (a *APIKeyAuth[T]) Permissions() Permissions {
+	if p, ok := any(&a.Principal).(Permissioned); ok {
+		return p.Permissions()
+	}
+	return Permissions{}
+}
+
+func (a *APIKeyAuth[T]) Authorize(r *http.Request) error {
+	if authorizer, ok := any(&a.Principal).(Authorizer); ok {
+		return authorizer.Authorize(r)
+	}
+	return nil
+}
+
+// Permissions of the session, none unless its data is Permissioned.
+func (s *SessionAuth[T]) Permissions() Permissions {
+	if p, ok := any(&s.Session).(Permissioned); ok {
+		return p.Permissions()
+	}
+	return Permissions{}
+}
+
+func (s *SessionAuth[T]) Authorize(r *http.Request) error {
+	if authorizer, ok := any(&s.Session).(Authorizer); ok {
+		return authorizer.Authorize(r)
+	}
+	return nil
+}
+
+// stringList reads a claim written either as a string or as a list of them.
+type stringList []string
+
+func (s *stringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := jsoniter.Unmarshal(data, &one); err == nil {
+		*s = strings.Fields(one)
+		return nil
+	}
+	var many []string
+	if err := jsoniter.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
@@ -0,0 +1,421 @@
+package mug
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// limits of the form bodies read by Form and Multipart
+var (
+	MaxFormSize      int64 = 1 << 20  // url-encoded bodies
+	MaxMultipartSize int64 = 32 << 20 // multipart bodies, files included
+	MultipartMemory  int64 = 1 << 20  // multipart bodies beyond it are streamed to temp files
+)
+
+// BodyDecoder is implemented by body mixins reading the request themselves instead of
+// decoding json, like Form and Multipart. They answer the request when it can't be read.
+type BodyDecoder interface {
+	DecodeBody(w http.ResponseWriter, r *http.Request) bool
+	// the media type the body is documented as
+	ContentType() string
+}
+
+// Form mixin binds a url-encoded body into Body, by the `form:"name"` tags of its fields.
+// Multipart bodies are read too.
+type Form[T any] struct {
+	Body T `json:"body"`
+}
+
+func (f *Form[T]) DecodeBody(w http.ResponseWriter, r *http.Request) bool {
+	return decodeForm(w, r, &f.Body)
+}
+
+func (f *Form[T]) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+// Multipart mixin binds a multipart body into Body, like Form, along with its files: fields of
+// type *multipart.FileHeader or []*multipart.FileHeader, limited by their tags:
+//
+//	Avatar *multipart.FileHeader `form:"avatar" accept:"image/png,image/jpeg" maxsize:"2MB" validate:"required"`
+//
+// The file types are sniffed from their content, which also replaces their Content-Type header.
+// Big files are streamed to temp files, removed once the handler returns.
+type Multipart[T any] struct {
+	Body T `json:"body"`
+}
+
+func (m *Multipart[T]) DecodeBody(w http.ResponseWriter, r *http.Request) bool {
+	return decodeForm(w, r, &m.Body)
+}
+
+func (m *Multipart[T]) ContentType() string {
+	return "multipart/form-data"
+}
+
+// formBody is implemented by Form and Multipart, and the inputs embedding them.
+type formBody interface {
+	formBodyType() reflect.Type
+}
+
+func (f *Form[T]) formBodyType() reflect.Type      { return reflect.TypeFor[T]() }
+func (m *Multipart[T]) formBodyType() reflect.Type { return reflect.TypeFor[T]() }
+
+// CheckForm reads the form body of input, a pointer to a handler's input, if it has one.
+// It panics on fields that can't be bound: spout calls it when the route is registered,
+// so a misdeclared handler fails at startup rather than on its first request.
+func CheckForm(input any) {
+	if form, ok := input.(formBody); ok {
+		formPlanOf(form.formBodyType())
+	}
+}
+
+// FormName is the name a field of Form and Multipart bodies is bound from: its form tag,
+// or else its json tag or its name. ok is false for fields that aren't bound.
+func FormName(field reflect.StructField) (name string, ok bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", false
+	}
+	name = field.Tag.Get("form")
+	if name == "" {
+		name = strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	}
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+var unsupportedFormPayload = `{
+	"error": "unsupported media type",
+	"message": "The body must be a form, url-encoded or multipart."
+}`
+var formTooLargePayload = `{
+	"error": "request too large",
+	"message": "The body must not be larger than %d bytes."
+}`
+var invalidFormPayload = `{
+	"error": "invalid form",
+	"message": %s
+}`
+
+func decodeForm(w http.ResponseWriter, r *http.Request, body any) bool {
+	plan := formPlanOf(reflect.TypeOf(body).Elem())
+	if !isForm(r) {
+		Error(w, unsupportedFormPayload, http.StatusUnsupportedMediaType)
+		return false
+	}
+	if !parseForm(w, r) {
+		return false
+	}
+
+	var files map[string][]*multipart.FileHeader
+	if r.MultipartForm != nil {
+		files = r.MultipartForm.File
+	}
+	// the same shape as the validation errors
+	errs := plan.bind(reflect.ValueOf(body).Elem(), r.PostForm, files)
+	if len(errs) > 0 {
+		payload, _ := jsoniter.Marshal(errs)
+		Error(w, string(payload), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// isForm tells url-encoded and multipart bodies.
+func isForm(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// parseForm parses the form body of r within MaxFormSize or MaxMultipartSize, answering the
+// request when it can't. Once parsed, the form is kept, so SessionAuth reading its CSRF field
+// and Form binding it share the same limits.
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	if r.PostForm != nil {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	limit := MaxFormSize
+	if mediaType == "multipart/form-data" {
+		limit = MaxMultipartSize
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		err = r.ParseMultipartForm(MultipartMemory)
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		err = r.ParseForm()
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		Error(w, fmt.Sprintf(formTooLargePayload, limit), http.StatusRequestEntityTooLarge)
+		return false
+	} else if err != nil {
+		Error(w, fmt.Sprintf(invalidFormPayload, jsonString(err.Error())), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+var (
+	fileType  = reflect.TypeFor[*multipart.FileHeader]()
+	filesType = reflect.TypeFor[[]*multipart.FileHeader]()
+)
+
+// formField is how a field of a form body is bound.
+type formField struct {
+	index   []int
+	name    string
+	accept  []string // media types of its files, like image/png or image/*
+	maxSize int64    // of each of its files, 0 for no limit but MaxMultipartSize
+}
+
+type formPlan []formField
+
+var formPlans sync.Map
+
+// formPlanOf reads the fields of t once. Fields that can't be bound are a programming error, so it panics.
+func formPlanOf(t reflect.Type) formPlan {
+	if plan, ok := formPlans.Load(t); ok {
+		return plan.(formPlan)
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("mug: form bodies must be structs, not %s", t))
+	}
+
+	var plan formPlan
+	for _, field := range reflect.VisibleFields(t) {
+		name, ok := FormName(field)
+		if !ok || throughPointer(t, field.Index) {
+			continue
+		}
+		f := formField{index: field.Index, name: name}
+		if field.Type == fileType || field.Type == filesType {
+			if accept := field.Tag.Get("accept"); accept != "" {
+				for _, mediaType := range strings.Split(accept, ",") {
+					f.accept = append(f.accept, strings.TrimSpace(mediaType))
+				}
+			}
+			if size := field.Tag.Get("maxsize"); size != "" {
+				var err error
+				if f.maxSize, err = parseSize(size); err != nil {
+					panic(fmt.Sprintf("mug: maxsize of %s.%s: %v", t, field.Name, err))
+				}
+			}
+		} else if !bindable(field.Type) {
+			panic(fmt.Sprintf("mug: can't bind the form field %s.%s of type %s", t, field.Name, field.Type))
+		}
+		plan = append(plan, f)
+	}
+	formPlans.Store(t, plan)
+	return plan
+}
+
+// throughPointer tells fields promoted from embedded pointers, which may be nil.
+func throughPointer(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		if t.FieldByIndex(index[:i]).Type.Kind() == reflect.Pointer {
+			return true
+		}
+	}
+	return false
+}
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// bindable tells the types form values can be parsed into.
+func bindable(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return bindable(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && bindable(t.Elem())
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// bind fills body, returning the errors of each field; missing fields are left to the validation.
+func (plan formPlan) bind(body reflect.Value, values url.Values, files map[string][]*multipart.FileHeader) map[string]string {
+	errs := map[string]string{}
+	for _, f := range plan {
+		field := body.FieldByIndex(f.index)
+
+		if field.Type() == fileType || field.Type() == filesType {
+			headers := files[f.name]
+			if len(headers) == 0 {
+				continue
+			}
+			for _, header := range headers {
+				if msg := f.check(header); msg != "" {
+					errs[f.name] = msg
+				}
+			}
+			if field.Type() == fileType {
+				field.Set(reflect.ValueOf(headers[0]))
+			} else {
+				field.Set(reflect.ValueOf(headers))
+			}
+			continue
+		}
+
+		raw, ok := values[f.name]
+		if !ok {
+			continue
+		}
+		if err := setFormValue(field, raw); err != nil {
+			errs[f.name] = f.name + " " + err.Error()
+		}
+	}
+	return errs
+}
+
+// check limits the size of the file and sniffs its type, saying what's wrong with it.
+func (f formField) check(header *multipart.FileHeader) string {
+	if f.maxSize > 0 && header.Size > f.maxSize {
+		return fmt.Sprintf("%s must not be larger than %s", f.name, formatSize(f.maxSize))
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return f.name + " can't be read"
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return f.name + " can't be read"
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	header.Header.Set("Content-Type", sniffed)
+
+	if len(f.accept) == 0 {
+		return ""
+	}
+	for _, accepted := range f.accept {
+		if accepted == sniffed || strings.HasSuffix(accepted, "/*") && strings.HasPrefix(sniffed, strings.TrimSuffix(accepted, "*")) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s must be %s, not %s", f.name, strings.Join(f.accept, " or "), sniffed)
+}
+
+// setFormValue parses the values of a field into it.
+func setFormValue(field reflect.Value, raw []string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(raw[0])); err != nil {
+			return errors.New("is not valid")
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		value := reflect.New(field.Type().Elem())
+		if err := setFormValue(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	case reflect.Slice:
+		// a value for each repeated key
+		values := reflect.MakeSlice(field.Type(), len(raw), len(raw))
+		for i := range raw {
+			if err := setFormValue(values.Index(i), raw[i:i+1]); err != nil {
+				return err
+			}
+		}
+		field.Set(values)
+		return nil
+	}
+
+	value := strings.TrimSpace(raw[0])
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw[0])
+	case reflect.Bool:
+		// checkboxes send "on"
+		b, err := strconv.ParseBool(value)
+		if value == "on" {
+			b, err = true, nil
+		}
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(n)
+	}
+	return nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// parseSize reads sizes like 512KB or 5MB, or plain bytes.
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(size, u.suffix) {
+			size, unit = strings.TrimSpace(strings.TrimSuffix(size, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * unit, nil
+}
+
+func formatSize(bytes int64) string {
+	for _, u := range sizeUnits {
+		if bytes >= u.bytes && bytes%u.bytes == 0 {
+			return strconv.FormatInt(bytes/u.bytes, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(bytes, 10) + "B"
+}
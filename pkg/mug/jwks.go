@@ -0,0 +1,192 @@
+package mug
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/sync/singleflight"
+)
+
+// jwks caches the keys of a JSON Web Key Set by kid.
+// They're fetched again when stale, or sooner when a token has an unknown kid,
+// which is how identity providers rotate their keys.
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	// fetches run outside mu, once for all the requests waiting on them
+	fetches singleflight.Group
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey // replaced by fetch, never modified
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+func newJWKS(url string, refresh time.Duration) *jwks {
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	return &jwks{url: url, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (j *jwks) key(kid string) (crypto.PublicKey, error) {
+	j.mu.RLock()
+	keys := j.keys
+	// once known, keys are refetched at most once a minute, so bad tokens can't flood the provider
+	_, known := keys[kid]
+	stale := time.Since(j.fetchedAt) > j.refresh
+	retry := time.Since(j.triedAt) > min(time.Minute, j.refresh)
+	j.mu.RUnlock()
+
+	if keys == nil || (stale || !known) && retry {
+		_, err, _ := j.fetches.Do("", func() (any, error) { return nil, j.fetch() })
+		j.mu.RLock()
+		keys = j.keys
+		j.mu.RUnlock()
+		if err != nil && keys == nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// tokens without kid work when the set has a single key
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no key %q in the JWKS", kid)
+}
+
+// fetch replaces the keys with the ones served now; the old ones stay if it fails.
+func (j *jwks) fetch() error {
+	j.mu.Lock()
+	j.triedAt = time.Now()
+	j.mu.Unlock()
+
+	keys, err := j.download()
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.keys, j.fetchedAt = keys, time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+// download reads the signing keys of the set served at the JWKS URL.
+func (j *jwks) download() (map[string]crypto.PublicKey, error) {
+	res, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the JWKS: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the JWKS: %s", res.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := jsoniter.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("reading the JWKS: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys of unsupported kinds are skipped, the others still work
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// jsonWebKey is a public key of a JWKS, as RFC 7517 and 7518 describe it.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64Int(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64Int(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64Int(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64Int(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		// rejects points off the curve
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func base64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,279 @@
+package mug
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig says how BearerAuth verifies tokens. Set it with ConfigureJWT;
+// until then it's read from the env on first use, see JWTConfigFromEnv.
+type JWTConfig struct {
+	// the accepted "alg" headers, e.g. RS256 or ES256. By default HS256 without keys,
+	// RS256 and ES256 with a PublicKeyFile or JWKSURL (and HS256 too if Secret is set).
+	Algorithms []string
+	// HMAC secret of the HS algorithms; JWT_TOKEN_SECRET when empty
+	Secret string
+	// PEM file with the RSA, ECDSA or Ed25519 public key of the tokens
+	PublicKeyFile string
+	// PEM file with the private key IssueToken signs with; its public part verifies
+	// the tokens when there's no PublicKeyFile. Without it, tokens are signed with Secret.
+	PrivateKeyFile string
+	// kid header of the issued tokens, to find the key in your JWKS
+	KeyID string
+	// JSON Web Key Set of the identity provider, whose keys are picked by the token's kid
+	JWKSURL string
+	// how long the keys of JWKSURL are kept; an hour if 0. Unknown kids fetch them sooner.
+	JWKSRefresh time.Duration
+
+	// required "iss" and "aud" (one of them) when set
+	Issuer   string
+	Audience string
+	// clock skew tolerated on exp, nbf and iat
+	Leeway time.Duration
+}
+
+// JWTConfigFromEnv reads JWT_ALGORITHMS (comma separated), JWT_PUBLIC_KEY_FILE, JWT_PRIVATE_KEY_FILE,
+// JWT_KEY_ID, JWT_JWKS_URL, JWT_JWKS_REFRESH, JWT_ISSUER, JWT_AUDIENCE and JWT_LEEWAY.
+// The secret stays JWT_TOKEN_SECRET.
+func JWTConfigFromEnv() (JWTConfig, error) {
+	cfg := JWTConfig{
+		PublicKeyFile:  os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		PrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		KeyID:          os.Getenv("JWT_KEY_ID"),
+		JWKSURL:        os.Getenv("JWT_JWKS_URL"),
+		Issuer:         os.Getenv("JWT_ISSUER"),
+		Audience:       os.Getenv("JWT_AUDIENCE"),
+	}
+	if algorithms := os.Getenv("JWT_ALGORITHMS"); algorithms != "" {
+		for _, alg := range strings.Split(algorithms, ",") {
+			cfg.Algorithms = append(cfg.Algorithms, strings.TrimSpace(alg))
+		}
+	}
+	for env, duration := range map[string]*time.Duration{"JWT_JWKS_REFRESH": &cfg.JWKSRefresh, "JWT_LEEWAY": &cfg.Leeway} {
+		if value := os.Getenv(env); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", env, err)
+			}
+			*duration = d
+		}
+	}
+	return cfg, nil
+}
+
+// jwtVerifier is a JWTConfig ready to verify and sign tokens.
+type jwtVerifier struct {
+	cfg       JWTConfig
+	parser    *jwt.Parser
+	publicKey crypto.PublicKey
+	jwks      *jwks
+
+	privateKey crypto.PrivateKey // nil when signing with the secret
+	signing    jwt.SigningMethod
+	algorithms []string // accepted by parser
+}
+
+var verifier struct {
+	sync.Mutex
+	v *jwtVerifier
+}
+
+// ConfigureJWT makes BearerAuth verify tokens as cfg says,
+// failing on unknown algorithms and unreadable keys.
+func ConfigureJWT(cfg JWTConfig) error {
+	v, err := newVerifier(cfg)
+	if err != nil {
+		return err
+	}
+	verifier.Lock()
+	defer verifier.Unlock()
+	verifier.v = v
+	return nil
+}
+
+// currentVerifier returns the configured verifier, reading the env the first time.
+func currentVerifier() (*jwtVerifier, error) {
+	verifier.Lock()
+	defer verifier.Unlock()
+	if verifier.v == nil {
+		cfg, err := JWTConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if verifier.v, err = newVerifier(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return verifier.v, nil
+}
+
+func newVerifier(cfg JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{cfg: cfg}
+
+	if cfg.PublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if v.publicKey, err = parsePublicKey(pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.PublicKeyFile, err)
+		}
+	}
+	if cfg.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if v.privateKey, v.signing, err = parsePrivateKey(pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.PrivateKeyFile, err)
+		}
+		if v.publicKey == nil {
+			v.publicKey = v.privateKey.(interface{ Public() crypto.PublicKey }).Public()
+		}
+	}
+	if cfg.JWKSURL != "" {
+		v.jwks = newJWKS(cfg.JWKSURL, cfg.JWKSRefresh)
+	}
+
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		if v.publicKey != nil || v.jwks != nil {
+			algorithms = []string{"RS256", "ES256"}
+		}
+		if v.signing != nil && !slices.Contains(algorithms, v.signing.Alg()) {
+			algorithms = append(algorithms, v.signing.Alg())
+		}
+		// with keys, HMAC only when asked for, so their public part can't sign tokens
+		if len(algorithms) == 0 || cfg.Secret != "" {
+			algorithms = append(algorithms, "HS256")
+		}
+	}
+	for _, alg := range algorithms {
+		if jwt.GetSigningMethod(alg) == nil || alg == "none" {
+			return nil, fmt.Errorf("unknown JWT algorithm %q", alg)
+		}
+	}
+	if v.signing == nil {
+		// the first HMAC algorithm allowed, when there's one
+		v.signing = jwt.SigningMethodHS256
+		for _, alg := range algorithms {
+			if method, ok := jwt.GetSigningMethod(alg).(*jwt.SigningMethodHMAC); ok {
+				v.signing = method
+				break
+			}
+		}
+	}
+
+	v.algorithms = algorithms
+	options := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithLeeway(cfg.Leeway)}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+	return v, nil
+}
+
+// parsePublicKey reads a PEM public key of any of the kinds jwt supports.
+func parsePublicKey(pem []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("not an RSA, ECDSA or Ed25519 public key in PEM")
+}
+
+// parsePrivateKey reads a PEM private key and the algorithm it signs with.
+func parsePrivateKey(pem []byte) (crypto.PrivateKey, jwt.SigningMethod, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(pem); err == nil {
+		switch key.Curve.Params().BitSize {
+		case 384:
+			return key, jwt.SigningMethodES384, nil
+		case 521:
+			return key, jwt.SigningMethodES512, nil
+		}
+		return key, jwt.SigningMethodES256, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+		return key, jwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, errors.New("not an RSA, ECDSA or Ed25519 private key in PEM")
+}
+
+// parse verifies the access token into claims; refresh tokens are refused.
+func (v *jwtVerifier) parse(token string, claims jwt.Claims) error {
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if t.Header["typ"] == refreshTokenType {
+			return nil, errors.New("refresh tokens can't be used as access tokens")
+		}
+		return v.key(t)
+	})
+	return err
+}
+
+// sign signs claims with the private key, or with the secret, as a token of typ.
+// Tokens the verifier would refuse aren't signed, e.g. HS256 ones when only a public key verifies them.
+func (v *jwtVerifier) sign(claims jwt.Claims, typ string) (string, error) {
+	if !slices.Contains(v.algorithms, v.signing.Alg()) {
+		return "", fmt.Errorf("can't sign %s tokens, only %s are accepted: set a PrivateKeyFile, or a Secret to accept HS256",
+			v.signing.Alg(), strings.Join(v.algorithms, ", "))
+	}
+	token := jwt.NewWithClaims(v.signing, claims)
+	token.Header["typ"] = typ
+	if v.cfg.KeyID != "" {
+		token.Header["kid"] = v.cfg.KeyID
+	}
+	if v.privateKey != nil {
+		return token.SignedString(v.privateKey)
+	}
+	secret, err := v.secret()
+	if err != nil {
+		return "", err
+	}
+	return token.SignedString(secret)
+}
+
+func (v *jwtVerifier) secret() ([]byte, error) {
+	secret := v.cfg.Secret
+	if secret == "" {
+		secret = JWT_TOKEN_SECRET
+	}
+	if secret == "" {
+		return nil, errors.New("no secret for HMAC tokens, set JWT_TOKEN_SECRET")
+	}
+	return []byte(secret), nil
+}
+
+// key picks the key verifying t, from its algorithm and kid.
+func (v *jwtVerifier) key(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret()
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	switch {
+	case v.jwks != nil && (kid != "" || v.publicKey == nil):
+		return v.jwks.key(kid)
+	case v.publicKey != nil:
+		return v.publicKey, nil
+	}
+	return nil, errors.New("no public key to verify the token")
+}
@@ -1,6 +1,7 @@
 package mug
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -17,43 +18,100 @@ type Authable interface {
 	Authenticate(w http.ResponseWriter, r *http.Request) bool
 }
 
+// ErrorDescriber is implemented by mixins that may answer the request on their own,
+// so their error responses are documented in the OpenAPI spec.
+type ErrorDescriber interface {
+	ErrorResponses() map[int]string
+}
+
+// SecurityScheme describes how an auth mixin reads its credentials, in OpenAPI terms.
+type SecurityScheme struct {
+	Name         string // key under components.securitySchemes, e.g. "bearerAuth"
+	Type         string // "http" or "apiKey"
+	Scheme       string // for http: "bearer" or "basic"
+	BearerFormat string // for http bearer, e.g. "JWT"
+	In           string // for apiKey: "header", "query" or "cookie"
+	ParamName    string // for apiKey: the name of the header, query parameter or cookie
+	Description  string
+}
+
+// SecurityDescriber is implemented by auth mixins, so the operations using them are documented as secured.
+// Any of the returned schemes is enough to authenticate.
+type SecurityDescriber interface {
+	SecuritySchemes() []SecurityScheme
+}
+
 // Auth is a convenience alias for BearerAuth with default RegisteredClaims
 type Auth = BearerAuth[jwt.RegisteredClaims]
 
-// BearerAuth mixin for Bearer token authentication with custom claims
+// BearerAuth mixin for Bearer token authentication with custom claims,
+// verified as ConfigureJWT says.
 type BearerAuth[T jwt.Claims] struct {
-	Claims T
+	Claims T `json:"-"`
+
+	// of the token itself, as T may not keep them
+	permissions Permissions
 }
 
 var validate = validator.New()
 
+func (b *BearerAuth[T]) ErrorResponses() map[int]string {
+	return map[int]string{
+		http.StatusUnauthorized: "Missing or invalid bearer token",
+		http.StatusForbidden:    "The bearer token has expired",
+	}
+}
+
+func (b *BearerAuth[T]) SecuritySchemes() []SecurityScheme {
+	return []SecurityScheme{{
+		Name:         "bearerAuth",
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}}
+}
+
 func (b *BearerAuth[T]) Authenticate(w http.ResponseWriter, r *http.Request) bool {
 	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
 	if token == "" {
-		http.Error(w, missingTokenPayload, http.StatusUnauthorized)
+		Error(w, missingTokenPayload, http.StatusUnauthorized)
 		return false
 	}
 
-	// Initialize Claims if it's a pointer or zero value
-	// For simple structs, we can just use &b.Claims
-	// But we need to be careful about initialization if T is a pointer type
+	v, err := currentVerifier()
+	if err != nil {
+		// a configuration error: spout logs it and answers 500
+		panic("mug: invalid JWT configuration: " + err.Error())
+	}
 
 	// Parse token
-	_, err := jwt.ParseWithClaims(token, any(&b.Claims).(jwt.Claims), func(t *jwt.Token) (interface{}, error) {
-		return []byte(JWT_TOKEN_SECRET), nil
-	})
+	err = v.parse(token, any(&b.Claims).(jwt.Claims))
+	if err == nil {
+		b.permissions = tokenPermissions(token)
+	}
 
 	if errors.Is(err, jwt.ErrTokenExpired) {
-		http.Error(w, tokenExpiredPayload, http.StatusForbidden)
+		Error(w, tokenExpiredPayload, http.StatusForbidden)
 		return false
 	} else if err != nil {
-		http.Error(w, fmt.Sprintf(invalidTokenPayload, err.Error()), http.StatusUnauthorized)
+		Error(w, fmt.Sprintf(invalidTokenPayload, jsonString(err.Error())), http.StatusUnauthorized)
 		return false
 	}
 
+	if id := tokenID(&b.Claims); id != "" {
+		revoked, err := revocationStore().Revoked(r.Context(), id)
+		if err != nil {
+			Error(w, internalErrorPayload, http.StatusInternalServerError)
+			return false
+		} else if revoked {
+			Error(w, revokedTokenPayload, http.StatusUnauthorized)
+			return false
+		}
+	}
+
 	// Validate claims
 	if err := validate.Struct(b); err != nil {
-		http.Error(w, fmt.Sprintf(invalidTokenPayload, err.Error()), http.StatusUnauthorized)
+		Error(w, fmt.Sprintf(invalidTokenPayload, jsonString(err.Error())), http.StatusUnauthorized)
 		return false
 	}
 
@@ -73,3 +131,18 @@ type JsonBody[T any] struct {
 func (j *JsonBody[T]) GetBodyPtr() any {
 	return &j.Body
 }
+
+// Contextable interface for structs that receive the request's context
+type Contextable interface {
+	SetContext(ctx context.Context)
+}
+
+// Context mixin gives the handler the request's context, carrying its trace span and deadline,
+// e.g. to link a rabbit.SendContext to the request.
+type Context struct {
+	Ctx context.Context `json:"-"`
+}
+
+func (c *Context) SetContext(ctx context.Context) {
+	c.Ctx = ctx
+}
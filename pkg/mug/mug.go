@@ -1,10 +1,12 @@
 package mug
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 
 	"github.com/golang-jwt/jwt/v5"
+	jsoniter "github.com/json-iterator/go"
 )
 
 type M map[string]any
@@ -20,6 +22,15 @@ type Public struct {
 	jwt.RegisteredClaims
 }
 
+// Error replies with a json error payload and the status code,
+// like http.Error does for plain text.
+func Error(w http.ResponseWriter, payload string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, payload)
+}
+
 // global vars
 
 var JWT_TOKEN_SECRET = os.Getenv("JWT_TOKEN_SECRET")
@@ -34,5 +45,28 @@ var tokenExpiredPayload = `{
 }`
 var invalidTokenPayload = `{
 	"error": "invalid token",
-	"message": "%s"
+	"message": %s
+}`
+var revokedTokenPayload = `{
+	"error": "token revoked",
+	"message": "The provided token has been revoked. Please authenticate again to obtain a new token."
+}`
+
+// jsonString quotes s for the payloads above, since errors may have quotes of their own.
+func jsonString(s string) string {
+	quoted, _ := jsoniter.MarshalToString(s)
+	return quoted
+}
+
+var missingKeyPayload = `{
+	"error": "missing API key",
+	"message": "An API key is required to access this resource, in the %s header."
+}`
+var invalidKeyPayload = `{
+	"error": "invalid API key",
+	"message": "The provided API key is not valid."
+}`
+var internalErrorPayload = `{
+	"error": "Internal server error",
+	"message": "The issue must be reported to the system administrator."
 }`
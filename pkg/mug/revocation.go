@@ -0,0 +1,78 @@
+package mug
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore remembers revoked token IDs (their "jti") until the tokens would have expired anyway.
+// BearerAuth refuses revoked access tokens and Refresher refuses revoked refresh tokens.
+type RevocationStore interface {
+	// Revoke revokes id until the given time, the zero time meaning forever.
+	// It reports whether id was revoked just now, false if it already was,
+	// which must be atomic so two refreshes of the same token can't both succeed.
+	Revoke(ctx context.Context, id string, until time.Time) (bool, error)
+	Revoked(ctx context.Context, id string) (bool, error)
+}
+
+var revocations struct {
+	sync.RWMutex
+	store RevocationStore
+}
+
+func init() {
+	SetRevocationStore(NewMemoryRevocations())
+}
+
+// SetRevocationStore replaces the in-memory store, e.g. by one in Redis shared by all the replicas.
+func SetRevocationStore(store RevocationStore) {
+	revocations.Lock()
+	defer revocations.Unlock()
+	revocations.store = store
+}
+
+func revocationStore() RevocationStore {
+	revocations.RLock()
+	defer revocations.RUnlock()
+	return revocations.store
+}
+
+// MemoryRevocations is the default RevocationStore, local to the process.
+type MemoryRevocations struct {
+	mu     sync.Mutex
+	until  map[string]time.Time
+	purged time.Time
+}
+
+func NewMemoryRevocations() *MemoryRevocations {
+	return &MemoryRevocations{until: map[string]time.Time{}}
+}
+
+func (m *MemoryRevocations) Revoke(_ context.Context, id string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.purged) > time.Minute {
+		// forget the tokens that expired since
+		for id, until := range m.until {
+			if !until.IsZero() && now.After(until) {
+				delete(m.until, id)
+			}
+		}
+		m.purged = now
+	}
+
+	if previous, ok := m.until[id]; ok && (previous.IsZero() || now.Before(previous)) {
+		return false, nil
+	}
+	m.until[id] = until
+	return true, nil
+}
+
+func (m *MemoryRevocations) Revoked(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.until[id]
+	return ok && (until.IsZero() || time.Now().Before(until)), nil
+}
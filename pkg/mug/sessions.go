@@ -0,0 +1,323 @@
+package mug
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// where SessionAuth reads the CSRF token of unsafe requests from: the header, or
+// the field of url-encoded and multipart forms
+var (
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+)
+
+// SessionConfig says how SessionAuth keeps sessions. Set it with ConfigureSessions;
+// until then it's read from the env on first use, see SessionConfigFromEnv.
+type SessionConfig struct {
+	// where sessions are kept; in memory if nil
+	Store SessionStore
+	// name of the cookie, "mug_session" if empty
+	Cookie string
+	// how long a session lasts from the login, 12 hours if 0
+	TTL time.Duration
+	// send the cookie over plain http too, for development
+	Insecure bool
+	// Lax if 0, so links from other sites keep the session but their forms don't
+	SameSite http.SameSite
+	Domain   string
+}
+
+// SessionConfigFromEnv reads MUG_SESSION_STORE: memory (the default), file:<dir>, or cookie
+// with the secret of MUG_SESSION_SECRET; and MUG_SESSION_COOKIE, MUG_SESSION_TTL and MUG_SESSION_INSECURE.
+func SessionConfigFromEnv() (SessionConfig, error) {
+	cfg := SessionConfig{Cookie: os.Getenv("MUG_SESSION_COOKIE"), Insecure: os.Getenv("MUG_SESSION_INSECURE") == "true"}
+	if ttl := os.Getenv("MUG_SESSION_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return cfg, fmt.Errorf("MUG_SESSION_TTL: %w", err)
+		}
+		cfg.TTL = d
+	}
+
+	var err error
+	switch store := os.Getenv("MUG_SESSION_STORE"); {
+	case store == "" || store == "memory":
+	case strings.HasPrefix(store, "file:"):
+		cfg.Store, err = NewFileSessions(strings.TrimPrefix(store, "file:"))
+	case store == "cookie":
+		cfg.Store, err = NewCookieSessions(os.Getenv("MUG_SESSION_SECRET"))
+	default:
+		err = fmt.Errorf("unknown session store %q", store)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("MUG_SESSION_STORE: %w", err)
+	}
+	return cfg, nil
+}
+
+var sessions struct {
+	sync.Mutex
+	cfg *SessionConfig
+}
+
+const defaultSessionCookie = "mug_session"
+
+// ConfigureSessions makes SessionAuth keep sessions as cfg says.
+func ConfigureSessions(cfg SessionConfig) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	sessions.cfg = withSessionDefaults(cfg)
+}
+
+// SetupSessions reads the session configuration from the env, unless ConfigureSessions was
+// called, so a bad one fails at startup instead of on the first login. spout.Serve calls it.
+func SetupSessions() error {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if sessions.cfg != nil {
+		return nil
+	}
+	cfg, err := SessionConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("mug: invalid session configuration: %w", err)
+	}
+	sessions.cfg = withSessionDefaults(cfg)
+	return nil
+}
+
+func withSessionDefaults(cfg SessionConfig) *SessionConfig {
+	if cfg.Store == nil {
+		cfg.Store = NewMemorySessions()
+	}
+	if cfg.Cookie == "" {
+		cfg.Cookie = defaultSessionCookie
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 12 * time.Hour
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	return &cfg
+}
+
+// currentSessions returns the session configuration, reading the env the first time.
+func currentSessions() SessionConfig {
+	if err := SetupSessions(); err != nil {
+		// a configuration error, unless served by spout.Serve: spout logs it and answers 500
+		panic(err.Error())
+	}
+	sessions.Lock()
+	defer sessions.Unlock()
+	return *sessions.cfg
+}
+
+// sessionCookie names the session cookie without setting up the store,
+// so the spec is generated whatever the env holds.
+func sessionCookie() string {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if sessions.cfg != nil {
+		return sessions.cfg.Cookie
+	}
+	if name := os.Getenv("MUG_SESSION_COOKIE"); name != "" {
+		return name
+	}
+	return defaultSessionCookie
+}
+
+// sessionRecord is what the store keeps: the data of the handlers and what protects it.
+type sessionRecord struct {
+	Data    jsoniter.RawMessage `json:"data"`
+	CSRF    string              `json:"csrf"`
+	Expires time.Time           `json:"expires"`
+}
+
+func (cfg SessionConfig) load(r *http.Request) (string, sessionRecord, error) {
+	cookie, err := r.Cookie(cfg.Cookie)
+	if err != nil || cookie.Value == "" {
+		return "", sessionRecord{}, ErrNoSession
+	}
+	data, err := cfg.Store.Load(r.Context(), cookie.Value)
+	if err != nil {
+		return "", sessionRecord{}, err
+	}
+	var record sessionRecord
+	if err := jsoniter.Unmarshal(data, &record); err != nil || time.Now().After(record.Expires) {
+		return "", sessionRecord{}, ErrNoSession
+	}
+	return cookie.Value, record, nil
+}
+
+func (cfg SessionConfig) save(ctx context.Context, w http.ResponseWriter, value string, record sessionRecord) error {
+	data, err := jsoniter.Marshal(record)
+	if err != nil {
+		return err
+	}
+	updated, err := cfg.Store.Save(ctx, value, data, time.Until(record.Expires))
+	if err != nil {
+		return err
+	}
+	if updated != value {
+		cfg.setCookie(w, updated, record.Expires)
+	}
+	return nil
+}
+
+func (cfg SessionConfig) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     cfg.Cookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  expires,
+		Secure:   !cfg.Insecure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	}
+	if expires.IsZero() {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// StartSession logs in: it keeps data in a new session and sets its cookie, returning
+// the session's CSRF token. A session the request had is ended, so it can't be fixated.
+func StartSession[T any](w http.ResponseWriter, r *http.Request, data T) (string, error) {
+	cfg := currentSessions()
+	if value, _, err := cfg.load(r); err == nil {
+		if err := cfg.Store.Delete(r.Context(), value); err != nil {
+			return "", err
+		}
+	}
+
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	record := sessionRecord{Data: raw, CSRF: newSessionID(), Expires: time.Now().Add(cfg.TTL)}
+	if err := cfg.save(r.Context(), w, "", record); err != nil {
+		return "", err
+	}
+	return record.CSRF, nil
+}
+
+// EndSession logs out: it deletes the request's session and clears its cookie.
+func EndSession(w http.ResponseWriter, r *http.Request) error {
+	cfg := currentSessions()
+	cfg.setCookie(w, "", time.Time{})
+	if value, _, err := cfg.load(r); err == nil {
+		return cfg.Store.Delete(r.Context(), value)
+	}
+	return nil
+}
+
+var missingSessionPayload = `{
+	"error": "missing session",
+	"message": "A valid session is required to access this resource. Please log in."
+}`
+var csrfPayload = `{
+	"error": "invalid CSRF token",
+	"message": "The request must carry the CSRF token of the session, in the %s header."
+}`
+
+// SessionAuth mixin authenticates with a session cookie, started with StartSession, and fills Session.
+// Requests with unsafe methods must also carry the session's CSRFToken, rendered in the forms or
+// sent by scripts in the X-CSRF-Token header.
+type SessionAuth[T any] struct {
+	Session   T      `json:"-"`
+	CSRFToken string `json:"-"`
+
+	w      http.ResponseWriter
+	r      *http.Request
+	value  string
+	record sessionRecord
+}
+
+func (s *SessionAuth[T]) ErrorResponses() map[int]string {
+	return map[int]string{
+		http.StatusUnauthorized: "Missing or expired session",
+		http.StatusForbidden:    "Missing or invalid CSRF token",
+	}
+}
+
+func (s *SessionAuth[T]) SecuritySchemes() []SecurityScheme {
+	return []SecurityScheme{{
+		Name:      "sessionCookie",
+		Type:      "apiKey",
+		In:        "cookie",
+		ParamName: sessionCookie(),
+	}}
+}
+
+func (s *SessionAuth[T]) Authenticate(w http.ResponseWriter, r *http.Request) bool {
+	cfg := currentSessions()
+	value, record, err := cfg.load(r)
+	if errors.Is(err, ErrNoSession) {
+		Error(w, missingSessionPayload, http.StatusUnauthorized)
+		return false
+	} else if err != nil {
+		Error(w, internalErrorPayload, http.StatusInternalServerError)
+		return false
+	}
+
+	if !safeMethod(r.Method) {
+		token := r.Header.Get(CSRFHeader)
+		if token == "" && isForm(r) {
+			if !parseForm(w, r) {
+				return false
+			}
+			token = r.PostForm.Get(CSRFField)
+		}
+		if !validCSRF(token, record.CSRF) {
+			Error(w, fmt.Sprintf(csrfPayload, CSRFHeader), http.StatusForbidden)
+			return false
+		}
+	}
+
+	if err := jsoniter.Unmarshal(record.Data, &s.Session); err != nil {
+		Error(w, missingSessionPayload, http.StatusUnauthorized)
+		return false
+	}
+	s.CSRFToken = record.CSRF
+	s.w, s.r, s.value, s.record = w, r, value, record
+	return true
+}
+
+// Save stores the changes the handler made to Session.
+func (s *SessionAuth[T]) Save() error {
+	data, err := jsoniter.Marshal(s.Session)
+	if err != nil {
+		return err
+	}
+	s.record.Data = data
+	return currentSessions().save(s.r.Context(), s.w, s.value, s.record)
+}
+
+// End logs out, like EndSession.
+func (s *SessionAuth[T]) End() error {
+	return EndSession(s.w, s.r)
+}
+
+// safeMethod tells the methods that must not change anything, so they need no CSRF token.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func validCSRF(token, expected string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
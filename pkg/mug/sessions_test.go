@@ -0,0 +1,27 @@
+package mug
+
+import "testing"
+
+func TestSetupSessions(t *testing.T) {
+	defer func() { sessions.cfg = nil }()
+	sessions.cfg = nil
+	t.Setenv("MUG_SESSION_STORE", "redis")
+	t.Setenv("MUG_SESSION_COOKIE", "espresso")
+
+	// the spec only needs the cookie's name
+	schemes := (&SessionAuth[struct{}]{}).SecuritySchemes()
+	if schemes[0].ParamName != "espresso" {
+		t.Errorf("cookie %q", schemes[0].ParamName)
+	}
+	if err := SetupSessions(); err == nil {
+		t.Errorf("unknown store accepted")
+	}
+
+	t.Setenv("MUG_SESSION_STORE", "memory")
+	if err := SetupSessions(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := currentSessions(); cfg.Cookie != "espresso" || cfg.Store == nil {
+		t.Errorf("sessions not set up from the env: %+v", cfg)
+	}
+}
@@ -0,0 +1,222 @@
+package mug
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned by session stores for unknown, expired or tampered sessions.
+var ErrNoSession = errors.New("no such session")
+
+// SessionStore keeps the sessions of SessionAuth. The cookie holds the value Save returns:
+// a session ID for stores on the server, or the session itself for CookieSessions.
+type SessionStore interface {
+	Load(ctx context.Context, value string) ([]byte, error)
+	// Save stores data for ttl under value, "" for a new session, and returns the cookie's new value.
+	Save(ctx context.Context, value string, data []byte, ttl time.Duration) (string, error)
+	Delete(ctx context.Context, value string) error
+}
+
+// newSessionID is long enough not to be guessed, and safe as a file name.
+func newSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// validSessionID refuses what newSessionID can't have made, like "../" in a forged cookie.
+func validSessionID(id string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil && len(b) == 32
+}
+
+// MemorySessions is the default SessionStore, local to the process.
+type MemorySessions struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	purged   time.Time
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+func NewMemorySessions() *MemorySessions {
+	return &MemorySessions{sessions: map[string]memorySession{}}
+}
+
+func (m *MemorySessions) Load(_ context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || time.Now().After(session.expires) {
+		return nil, ErrNoSession
+	}
+	return session.data, nil
+}
+
+func (m *MemorySessions) Save(_ context.Context, id string, data []byte, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.purged) > time.Minute {
+		for id, session := range m.sessions {
+			if now.After(session.expires) {
+				delete(m.sessions, id)
+			}
+		}
+		m.purged = now
+	}
+
+	if id == "" {
+		id = newSessionID()
+	}
+	m.sessions[id] = memorySession{data: data, expires: now.Add(ttl)}
+	return id, nil
+}
+
+func (m *MemorySessions) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// FileSessions keeps each session in a file of its directory, so they survive restarts.
+// Each file starts with the unix time it expires at, on a line of its own.
+type FileSessions struct {
+	dir string
+}
+
+// NewFileSessions stores the sessions in dir, creating it if needed.
+func NewFileSessions(dir string) (*FileSessions, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileSessions{dir: dir}, nil
+}
+
+func (f *FileSessions) path(id string) string {
+	return filepath.Join(f.dir, id+".session")
+}
+
+func (f *FileSessions) Load(_ context.Context, id string) ([]byte, error) {
+	if !validSessionID(id) {
+		return nil, ErrNoSession
+	}
+	content, err := os.ReadFile(f.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoSession
+	} else if err != nil {
+		return nil, err
+	}
+	expires, data, _ := bytes.Cut(content, []byte("\n"))
+	unix, err := strconv.ParseInt(string(expires), 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		os.Remove(f.path(id))
+		return nil, ErrNoSession
+	}
+	return data, nil
+}
+
+func (f *FileSessions) Save(_ context.Context, id string, data []byte, ttl time.Duration) (string, error) {
+	if id == "" {
+		id = newSessionID()
+	} else if !validSessionID(id) {
+		return "", ErrNoSession
+	}
+	content := append([]byte(strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)+"\n"), data...)
+
+	// written aside and renamed, so a crash never leaves half a session
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return id, os.Rename(tmp.Name(), f.path(id))
+}
+
+func (f *FileSessions) Delete(_ context.Context, id string) error {
+	if !validSessionID(id) {
+		return nil
+	}
+	if err := os.Remove(f.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// CookieSessions keeps nothing on the server: the session is the cookie, encrypted and
+// authenticated with AES-GCM. Sessions must fit in a cookie, and ending one only clears
+// the cookie, so a copy of it stays valid until it expires.
+type CookieSessions struct {
+	aead cipher.AEAD
+}
+
+// NewCookieSessions encrypts the sessions with a key derived from secret.
+func NewCookieSessions(secret string) (*CookieSessions, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("the session secret must have at least 32 characters")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieSessions{aead: aead}, nil
+}
+
+// browsers drop bigger cookies
+const maxCookieSize = 4000
+
+func (c *CookieSessions) Load(_ context.Context, value string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, ErrNoSession
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	data, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	return data, nil
+}
+
+// Save seals data; the expiry is kept by SessionAuth inside it.
+func (c *CookieSessions) Save(_ context.Context, _ string, data []byte, _ time.Duration) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	rand.Read(nonce)
+	value := base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, data, nil))
+	if len(value) > maxCookieSize {
+		return "", errors.New("the session is too big for a cookie, use a store on the server")
+	}
+	return value, nil
+}
+
+func (c *CookieSessions) Delete(context.Context, string) error {
+	return nil
+}
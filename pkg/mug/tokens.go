@@ -0,0 +1,286 @@
+package mug
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// the "typ" header of refresh tokens, which BearerAuth refuses
+const refreshTokenType = "refresh+jwt"
+
+// ErrTokenRevoked is returned when refreshing with a revoked refresh token, or one already used.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// IssueToken signs claims as an access token valid for ttl, with the key of the JWTConfig BearerAuth
+// verifies with: PrivateKeyFile, or else the secret. claims are either jwt.MapClaims or a pointer
+// to a struct embedding jwt.RegisteredClaims, which gets its "iat", "exp" and "jti" and, when
+// the config requires them, its "iss" and "aud".
+func IssueToken(claims jwt.Claims, ttl time.Duration) (string, error) {
+	v, err := currentVerifier()
+	if err != nil {
+		return "", err
+	}
+	if err := stamp(claims, v.cfg, ttl); err != nil {
+		return "", err
+	}
+	return v.sign(claims, "JWT")
+}
+
+// RevokeToken revokes an access token until it expires, e.g. on logout.
+// Tokens without a "jti" can't be revoked; expired tokens need not be.
+func RevokeToken(ctx context.Context, token string) error {
+	v, err := currentVerifier()
+	if err != nil {
+		return err
+	}
+	claims := &jwt.RegisteredClaims{}
+	if err := v.parse(token, claims); errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return errors.New("the token has no jti to revoke it by")
+	}
+	_, err = revocationStore().Revoke(ctx, claims.ID, expiry(claims))
+	return err
+}
+
+// TokenPair is what a login or a refresh answers, as OAuth2 token responses do.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresher issues refresh tokens along with the access tokens, and rotates them:
+// each refresh token is traded once for a new pair. Using one twice means it was stolen,
+// so all the refresh tokens descending from the same login are revoked.
+type Refresher struct {
+	AccessTTL  time.Duration // 15 minutes if 0
+	RefreshTTL time.Duration // 30 days if 0
+
+	// Claims of the new access token of subject, read again on each refresh
+	// so changed roles and deleted accounts take effect. Required by Refresh.
+	Claims func(ctx context.Context, subject string) (jwt.Claims, error)
+}
+
+// refreshClaims of refresh tokens, with the login (their family) they descend from.
+type refreshClaims struct {
+	jwt.RegisteredClaims
+	Family string `json:"fam"`
+}
+
+// Issue answers a login with an access token of claims, which need a subject, and a refresh token.
+func (rf *Refresher) Issue(ctx context.Context, claims jwt.Claims) (TokenPair, error) {
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if subject == "" {
+		return TokenPair{}, errors.New("mug: the claims of refreshable tokens need a subject")
+	}
+	return rf.issue(claims, subject, newTokenID())
+}
+
+// Refresh trades a refresh token for a new pair. Besides jwt's errors and the store's,
+// it fails with ErrTokenRevoked when the token was revoked or used already.
+func (rf *Refresher) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	if rf.Claims == nil {
+		return TokenPair{}, errors.New("mug: Refresher.Claims is required to refresh tokens")
+	}
+	v, err := currentVerifier()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	claims, err := v.parseRefresh(refreshToken)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	store := revocationStore()
+	if revoked, err := store.Revoked(ctx, claims.Family); err != nil {
+		return TokenPair{}, err
+	} else if revoked {
+		return TokenPair{}, ErrTokenRevoked
+	}
+	first, err := store.Revoke(ctx, claims.ID, expiry(&claims.RegisteredClaims))
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !first {
+		// replayed: whoever else holds the family loses it too
+		if _, err := store.Revoke(ctx, claims.Family, time.Now().Add(rf.refreshTTL())); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrTokenRevoked
+	}
+
+	access, err := rf.Claims(ctx, claims.Subject)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return rf.issue(access, claims.Subject, claims.Family)
+}
+
+// Revoke revokes refreshToken and every other refresh token of its login, e.g. on logout.
+func (rf *Refresher) Revoke(ctx context.Context, refreshToken string) error {
+	v, err := currentVerifier()
+	if err != nil {
+		return err
+	}
+	claims, err := v.parseRefresh(refreshToken)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// the family outlives its token: later ones were issued up to RefreshTTL after it
+	_, err = revocationStore().Revoke(ctx, claims.Family, time.Now().Add(rf.refreshTTL()))
+	return err
+}
+
+func (rf *Refresher) issue(claims jwt.Claims, subject, family string) (TokenPair, error) {
+	access, err := IssueToken(claims, rf.accessTTL())
+	if err != nil {
+		return TokenPair{}, err
+	}
+	v, err := currentVerifier()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh := &refreshClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Family: family}
+	if err := stamp(refresh, v.cfg, rf.refreshTTL()); err != nil {
+		return TokenPair{}, err
+	}
+	signed, err := v.sign(refresh, refreshTokenType)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(rf.accessTTL().Seconds()),
+		RefreshToken: signed,
+	}, nil
+}
+
+func (rf *Refresher) accessTTL() time.Duration {
+	if rf.AccessTTL > 0 {
+		return rf.AccessTTL
+	}
+	return 15 * time.Minute
+}
+
+func (rf *Refresher) refreshTTL() time.Duration {
+	if rf.RefreshTTL > 0 {
+		return rf.RefreshTTL
+	}
+	return 30 * 24 * time.Hour
+}
+
+// parseRefresh verifies a refresh token; access tokens are refused.
+func (v *jwtVerifier) parseRefresh(token string) (*refreshClaims, error) {
+	claims := &refreshClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if t.Header["typ"] != refreshTokenType {
+			return nil, errors.New("not a refresh token")
+		}
+		return v.key(t)
+	})
+	return claims, err
+}
+
+// stamp sets the registered claims IssueToken fills in.
+func stamp(claims jwt.Claims, cfg JWTConfig, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("mug: tokens need a positive ttl")
+	}
+	now := time.Now()
+
+	if m, ok := claims.(jwt.MapClaims); ok {
+		m["iat"] = now.Unix()
+		m["exp"] = now.Add(ttl).Unix()
+		if _, ok := m["jti"]; !ok {
+			m["jti"] = newTokenID()
+		}
+		if _, ok := m["iss"]; !ok && cfg.Issuer != "" {
+			m["iss"] = cfg.Issuer
+		}
+		if _, ok := m["aud"]; !ok && cfg.Audience != "" {
+			m["aud"] = cfg.Audience
+		}
+		return nil
+	}
+
+	rc := registeredClaims(claims)
+	if rc == nil {
+		return fmt.Errorf("mug: can't issue %T, pass jwt.MapClaims or a pointer to claims embedding jwt.RegisteredClaims", claims)
+	}
+	rc.IssuedAt = jwt.NewNumericDate(now)
+	rc.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	if rc.ID == "" {
+		rc.ID = newTokenID()
+	}
+	if rc.Issuer == "" {
+		rc.Issuer = cfg.Issuer
+	}
+	if len(rc.Audience) == 0 && cfg.Audience != "" {
+		rc.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
+	return nil
+}
+
+// registeredClaims finds the jwt.RegisteredClaims of claims: themselves, or embedded in the struct they point to.
+func registeredClaims(claims any) *jwt.RegisteredClaims {
+	if rc, ok := claims.(*jwt.RegisteredClaims); ok {
+		return rc
+	}
+	v := reflect.ValueOf(claims)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.Elem().FieldByName("RegisteredClaims")
+	if !field.IsValid() || field.Type() != reflect.TypeFor[jwt.RegisteredClaims]() {
+		return nil
+	}
+	return field.Addr().Interface().(*jwt.RegisteredClaims)
+}
+
+// tokenID is the "jti" of verified claims, "" when they have none.
+func tokenID(claims any) string {
+	switch m := claims.(type) {
+	case jwt.MapClaims:
+		id, _ := m["jti"].(string)
+		return id
+	case *jwt.MapClaims:
+		id, _ := (*m)["jti"].(string)
+		return id
+	}
+	if rc := registeredClaims(claims); rc != nil {
+		return rc.ID
+	}
+	return ""
+}
+
+// expiry is when claims expire, or the zero time if never.
+func expiry(claims *jwt.RegisteredClaims) time.Time {
+	if claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,38 @@
+package rabbit
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sh-lucas/mug/pkg/metrics"
+)
+
+var (
+	publishedTotal = metrics.NewCounter("rabbit_published_total",
+		"Messages confirmed by the broker, by queue.", "queue")
+	publishFailuresTotal = metrics.NewCounter("rabbit_publish_failures_total",
+		"Messages that could not be published, by queue.", "queue")
+	consumedTotal = metrics.NewCounter("rabbit_consumed_total",
+		"Messages settled by the consumers, by queue and result (ack, nack or reject).", "queue", "result")
+	handlerDuration = metrics.NewHistogram("rabbit_handler_duration_seconds",
+		"Time taken by the consumers to handle a message, by queue.", nil, "queue")
+)
+
+// countingAcknowledger counts how the handler settles each message.
+type countingAcknowledger struct {
+	amqp.Acknowledger
+	queue string
+}
+
+func (c countingAcknowledger) Ack(tag uint64, multiple bool) error {
+	consumedTotal.Inc(c.queue, "ack")
+	return c.Acknowledger.Ack(tag, multiple)
+}
+
+func (c countingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	consumedTotal.Inc(c.queue, "nack")
+	return c.Acknowledger.Nack(tag, multiple, requeue)
+}
+
+func (c countingAcknowledger) Reject(tag uint64, requeue bool) error {
+	consumedTotal.Inc(c.queue, "reject")
+	return c.Acknowledger.Reject(tag, requeue)
+}
@@ -2,21 +2,45 @@ package rabbit
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sh-lucas/mug/pkg"
+	"github.com/sh-lucas/mug/pkg/health"
+	"github.com/sh-lucas/mug/pkg/metrics"
+	"go.opentelemetry.io/otel/codes"
 )
 
 var rabbitUri string
 var timeout = 30 * time.Second
 var runningInTest bool
+var logPayloads bool // RABBIT_LOG_PAYLOADS, off since messages may carry personal data
+
+// logs is swapped atomically, as workers log while SetLogger runs.
+var logs atomic.Pointer[slog.Logger]
+
+func init() {
+	logs.Store(pkg.NewLogger(os.Stderr))
+}
+
+func logger() *slog.Logger {
+	return logs.Load()
+}
+
+// SetLogger replaces the logger of rabbit.
+func SetLogger(l *slog.Logger) {
+	logs.Store(l)
+}
 
 func detectRunningInTest() bool {
 	if strings.HasSuffix(os.Args[0], ".test") {
@@ -32,6 +56,7 @@ func detectRunningInTest() bool {
 
 func init() {
 	runningInTest = detectRunningInTest()
+	health.Register(health.Check{Name: "rabbit", Func: ping})
 	go startup()
 }
 
@@ -50,18 +75,20 @@ func startup() {
 		}
 	}
 
+	logPayloads, _ = strconv.ParseBool(os.Getenv("RABBIT_LOG_PAYLOADS"))
+
 	if runningInTest {
 		return
 	}
 
 	rabbitUri = os.Getenv("RABBIT_URI")
 	if rabbitUri == "" {
-		log.Println(pkg.BoldRed + "You need to set the RABBIT_URI environment variable" + pkg.Reset)
+		logger().Error("you need to set the RABBIT_URI environment variable")
 	}
 
 	go func() {
 		for connKeeper() {
-			log.Println("Panic catched, restarting connection keeper...")
+			logger().Warn("panic caught, restarting the connection keeper")
 			time.Sleep(1 * time.Second)
 		}
 	}()
@@ -77,7 +104,7 @@ var conn Conn
 func connKeeper() (crash bool) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Println("Recovered in connKeeper():", r)
+			logger().Error("recovered in the connection keeper", "panic", r)
 			crash = true
 		}
 	}()
@@ -114,29 +141,44 @@ func connKeeper() (crash bool) {
 			err = <-watchedConn.NotifyClose(make(chan *amqp.Error))
 		}
 
-		log.Println("Connection closed:", err)
+		logger().Warn("connection closed", "error", err)
 		time.Sleep(200 * time.Millisecond)
 	}
 }
 
 var channels = make(chan *amqp.Channel, 50)
 
+// Send publishes payload as json to queue, waiting for the broker to confirm it.
 func Send(queue string, payload any) (ok bool) {
+	return SendContext(context.Background(), queue, payload)
+}
+
+// SendContext works like Send, continuing the trace of ctx: the W3C traceparent
+// goes in the message headers, so the consumer's span is a child of this one.
+func SendContext(ctx context.Context, queue string, payload any) (ok bool) {
+	span, headers := startPublish(ctx, queue)
+	defer span.End()
 	defer func() {
 		if r := recover(); r != nil {
-			log.Println("Recovered in Send():", r)
+			logger().Error("recovered in Send", "queue", queue, "panic", r)
 			ok = false
 		}
+		if !ok {
+			span.SetStatus(codes.Error, "message not published")
+			if metrics.Enabled {
+				publishFailuresTotal.Inc(queue)
+			}
+		}
 	}()
 
 	body, err := jsoniter.Marshal(payload)
 	if err != nil {
-		log.Println("Failed to marshal payload:", err)
+		logger().Error("failed to marshal the payload", "queue", queue, "error", err)
 		return false
 	}
 
 	if runningInTest {
-		log.Println("Test mode: Message not sent to RabbitMQ. Returning true from Send().")
+		logger().Debug("test mode: message not sent to RabbitMQ", "queue", queue)
 		return true
 	}
 
@@ -144,18 +186,18 @@ func Send(queue string, payload any) (ok bool) {
 	case chann := <-channels:
 		// gets a chann from the pool, verifies if it's still open
 		if chann != nil && !chann.IsClosed() {
-			ok = publish(chann, queue, body)
+			ok = publish(ctx, chann, queue, body, headers)
 		} else {
 			// reconnects if not
 			if chann != nil {
 				chann.Close()
 			}
 			chann = newChan()
-			ok = publish(chann, queue, body)
+			ok = publish(ctx, chann, queue, body, headers)
 		}
 	default:
 		chann := newChan()
-		ok = publish(chann, queue, body)
+		ok = publish(ctx, chann, queue, body, headers)
 	}
 
 	return ok
@@ -163,14 +205,18 @@ func Send(queue string, payload any) (ok bool) {
 
 // publish automatically returns the channel to the pool after publishing the message
 // it returns false to any error, including a perfectly timed closed channel.
-func publish(ch *amqp.Channel, queueName string, body []byte) (ok bool) {
+func publish(ctx context.Context, ch *amqp.Channel, queueName string, body []byte, headers amqp.Table) (ok bool) {
 	if ch.IsClosed() {
 		ch = newChan()
 	}
-	log.Println("Publishing message to RabbitMQ:", string(body))
+	attrs := []any{"queue", queueName, "bytes", len(body)}
+	if logPayloads {
+		attrs = append(attrs, "payload", string(body))
+	}
+	logger().Debug("publishing message", attrs...)
 
-	// sends the payload and stuff
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// sends the payload and stuff; a finished request doesn't cancel its message
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
 	defer cancel()
 
 	// Enable publisher confirms on the channel
@@ -182,10 +228,14 @@ func publish(ch *amqp.Channel, queueName string, body []byte) (ok bool) {
 		false,     // immediate
 		amqp.Publishing{
 			ContentType: "text/plain",
+			Headers:     headers,
 			Body:        body,
 		},
 	)
 	if err == nil && confirm != nil && confirm.Wait() {
+		if metrics.Enabled {
+			publishedTotal.Inc(queueName)
+		}
 		// channel still alive <3
 		select {
 		case channels <- ch:
@@ -194,7 +244,7 @@ func publish(ch *amqp.Channel, queueName string, body []byte) (ok bool) {
 		}
 		return true
 	} else {
-		log.Printf("Failed to publish message, confirm=%v, error: %v", confirm, err)
+		logger().Error("failed to publish message", "queue", queueName, "confirm", confirm, "error", err)
 		return false
 	}
 }
@@ -220,7 +270,7 @@ func newChan() (ch *amqp.Channel) {
 		// set up confirm mode
 		err = ch.Confirm(false)
 		if err != nil {
-			log.Printf("Failed to enable confirm mode: %v", err)
+			logger().Warn("failed to enable confirm mode", "error", err)
 			ch.Close()
 			time.Sleep(200 * time.Millisecond)
 			continue // recreates the channel if something goes wrong
@@ -232,12 +282,12 @@ func newChan() (ch *amqp.Channel) {
 		// needs a recover if chann turns nil
 		defer func() {
 			if r := recover(); r != nil {
-				log.Println("Recovered in channel close listener:", r)
+				logger().Error("recovered in the channel close listener", "panic", r)
 			}
 		}()
 		err := <-c.NotifyClose(make(chan *amqp.Error, 1))
 		if err != nil {
-			log.Printf("Channel closed: %v", err)
+			logger().Warn("channel closed", "error", err)
 		}
 	}(ch)
 
@@ -248,7 +298,7 @@ func newChan() (ch *amqp.Channel) {
 // Returns true if the connection is healthy without creating any queues or exchanges.
 func Ping() bool {
 	if runningInTest {
-		log.Println("Test mode: Ping returning true (not actually checking RabbitMQ)")
+		logger().Debug("test mode: Ping returning true without checking RabbitMQ")
 		return true
 	}
 
@@ -270,17 +320,31 @@ func Ping() bool {
 	return !ch.IsClosed()
 }
 
+// ping is Ping as a readiness check, see spout.ServeHealth.
+func ping(context.Context) error {
+	if !Ping() {
+		return errors.New("no connection to RabbitMQ")
+	}
+	return nil
+}
+
 // Subscribe starts a pool of workers to process messages from the specified queue.
 func Subscribe(queueName string, maxWorkers int, handler func(amqp.Delivery)) {
+	SubscribeContext(queueName, maxWorkers, func(_ context.Context, msg amqp.Delivery) { handler(msg) })
+}
+
+// SubscribeContext works like Subscribe, handing each message's context to handler:
+// it carries the consumer span, child of the one that published the message.
+func SubscribeContext(queueName string, maxWorkers int, handler func(context.Context, amqp.Delivery)) {
 	if runningInTest {
-		log.Printf("Test mode: WorkOnPool for queue '%s' not started", queueName)
+		logger().Debug("test mode: workers not started", "queue", queueName)
 		return
 	}
 
 	// limits the number of concurrent workers
 	workerSem := make(chan struct{}, maxWorkers)
 
-	log.Printf("Starting worker pool for queue '%s' with max %d workers", queueName, maxWorkers)
+	logger().Info("starting worker pool", "queue", queueName, "workers", maxWorkers)
 
 	// controls the spawn of workers
 	go func() {
@@ -292,18 +356,18 @@ func Subscribe(queueName string, maxWorkers int, handler func(amqp.Delivery)) {
 	}()
 }
 
-func processWorker(queueName string, workerSem <-chan struct{}, handler func(amqp.Delivery)) {
+func processWorker(queueName string, workerSem <-chan struct{}, handler func(context.Context, amqp.Delivery)) {
 	defer func() { <-workerSem }() // release the semaphore when done
 	defer func() {                 // recovers from panics just to be sure
 		if r := recover(); r != nil {
-			log.Printf("Worker recovered from panic: %v", r)
+			logger().Error("worker recovered from panic", "queue", queueName, "panic", r)
 		}
 	}()
 
 	// owns it's own channel
 	ch := newWorkerChannel()
 	if ch == nil {
-		log.Printf("Failed to create worker channel, exiting...")
+		logger().Error("failed to create the worker channel, exiting", "queue", queueName)
 		time.Sleep(2 * time.Second)
 		return
 	}
@@ -311,7 +375,7 @@ func processWorker(queueName string, workerSem <-chan struct{}, handler func(amq
 
 	_, err := ch.QueueDeclare(queueName, true, false, false, false, nil)
 	if err != nil {
-		log.Printf("Failed to declare queue '%s': %v", queueName, err)
+		logger().Error("failed to declare queue", "queue", queueName, "error", err)
 		time.Sleep(2 * time.Second)
 		return
 	}
@@ -319,7 +383,7 @@ func processWorker(queueName string, workerSem <-chan struct{}, handler func(amq
 	// starts consuming with prefetch 5
 	err = ch.Qos(5, 0, false)
 	if err != nil {
-		log.Printf("Failed to set QoS: %v", err)
+		logger().Error("failed to set QoS", "queue", queueName, "error", err)
 		time.Sleep(2 * time.Second)
 		return
 	}
@@ -327,31 +391,44 @@ func processWorker(queueName string, workerSem <-chan struct{}, handler func(amq
 	// Consume
 	msgs, err := ch.Consume(queueName, "", false, false, false, false, nil)
 	if err != nil {
-		log.Printf("Failed to consume: %v", err)
+		logger().Error("failed to consume", "queue", queueName, "error", err)
 		time.Sleep(2 * time.Second)
 		return
 	}
 
-	log.Printf("Worker started consuming from '%s'", queueName)
+	logger().Debug("worker started consuming", "queue", queueName)
 
 	// Processa mensagens
 	for msg := range msgs {
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Handler panic: %v", r)
-					msg.Nack(false, false) // nacks so it doesn't crash again
-				}
-			}()
-
-			handler(msg)
-		}()
+		deliver(queueName, msg, handler)
 	}
 
-	log.Printf("Worker channel closed, will restart in 2s...")
+	logger().Warn("worker channel closed, restarting in 2s", "queue", queueName)
 	time.Sleep(2 * time.Second)
 }
 
+// deliver hands msg to handler inside its consumer span, nacking it if handler panics.
+func deliver(queueName string, msg amqp.Delivery, handler func(context.Context, amqp.Delivery)) {
+	if metrics.Enabled {
+		msg.Acknowledger = countingAcknowledger{msg.Acknowledger, queueName}
+		defer func(start time.Time) {
+			handlerDuration.Observe(time.Since(start).Seconds(), queueName)
+		}(time.Now())
+	}
+	ctx, span := startConsume(queueName, msg)
+	defer span.End()
+	defer func() {
+		if r := recover(); r != nil {
+			logger().Error("handler panic", "queue", queueName, "panic", r, "stack", string(debug.Stack()))
+			span.RecordError(panicError(r))
+			span.SetStatus(codes.Error, "handler panic")
+			msg.Nack(false, false) // nacks so it doesn't crash again
+		}
+	}()
+
+	handler(ctx, msg)
+}
+
 func newWorkerChannel() *amqp.Channel {
 	for i := 0; i < 50; i++ { // Max 50 tentativas
 		conn.m.RLock()
@@ -0,0 +1,72 @@
+package rabbit
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every message, so it follows the global provider when it's replaced.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/sh-lucas/mug/pkg/rabbit")
+}
+
+// headerCarrier lets the propagators read and write the W3C traceparent in message headers.
+type headerCarrier amqp.Table
+
+func (h headerCarrier) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startPublish starts the producer span of a message and returns the headers carrying it.
+func startPublish(ctx context.Context, queue string) (trace.Span, amqp.Table) {
+	ctx, span := tracer().Start(ctx, "send "+queue,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitMQ,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(queue),
+		),
+	)
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+	return span, headers
+}
+
+// startConsume starts the consumer span of a delivery, child of the span that published it.
+func startConsume(queue string, msg amqp.Delivery) (context.Context, trace.Span) {
+	ctx := context.Background()
+	if msg.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Headers))
+	}
+	return tracer().Start(ctx, "process "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitMQ,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(queue),
+			semconv.MessagingMessageBodySize(len(msg.Body)),
+		),
+	)
+}
+
+func panicError(r any) error {
+	return fmt.Errorf("panic: %v", r)
+}
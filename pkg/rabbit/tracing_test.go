@@ -0,0 +1,45 @@
+package rabbit
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestConsumerSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	producer, headers := startPublish(context.Background(), "orders")
+	producer.End()
+
+	var handled trace.SpanContext
+	deliver("orders", amqp.Delivery{Headers: headers, Body: []byte(`"hi"`)}, func(ctx context.Context, _ amqp.Delivery) {
+		handled = trace.SpanContextFromContext(ctx)
+	})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the producer and consumer spans, got %d", len(spans))
+	}
+	consumer := spans[1]
+	if consumer.Name() != "process orders" || consumer.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("unexpected consumer span %q (%s)", consumer.Name(), consumer.SpanKind())
+	}
+	if consumer.Parent().SpanID() != producer.SpanContext().SpanID() || consumer.SpanContext().TraceID() != producer.SpanContext().TraceID() {
+		t.Errorf("consumer span not extracted from the delivery headers: parent %s, want %s", consumer.Parent().SpanID(), producer.SpanContext().SpanID())
+	}
+	if handled.SpanID() != consumer.SpanContext().SpanID() {
+		t.Errorf("handler didn't get the consumer span")
+	}
+}
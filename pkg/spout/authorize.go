@@ -0,0 +1,80 @@
+package spout
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+// Requirement is what a route demands of who authenticated, from its // mug:require annotation:
+//
+//	// mug:require role=admin,support scope=orders:write
+type Requirement struct {
+	Roles  []string // any of them
+	Scopes []string // all of them
+}
+
+func (req Requirement) empty() bool {
+	return len(req.Roles) == 0 && len(req.Scopes) == 0
+}
+
+// String describes the requirement, e.g. "role admin or support and scope orders:write".
+func (req Requirement) String() string {
+	parts := []string{}
+	if len(req.Roles) > 0 {
+		parts = append(parts, "role "+strings.Join(req.Roles, " or "))
+	}
+	if len(req.Scopes) == 1 {
+		parts = append(parts, "scope "+req.Scopes[0])
+	} else if len(req.Scopes) > 1 {
+		parts = append(parts, "scopes "+strings.Join(req.Scopes, ", "))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// missing says what p lacks to satisfy req, "" if nothing.
+func (req Requirement) missing(p mug.Permissions) string {
+	if len(req.Roles) > 0 && !slices.ContainsFunc(req.Roles, func(role string) bool { return slices.Contains(p.Roles, role) }) {
+		return "role " + strings.Join(req.Roles, " or ")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(p.Scopes, scope) {
+			return "scope " + scope
+		}
+	}
+	return ""
+}
+
+var forbiddenPayload = `{
+	"error": "forbidden",
+	"message": %s
+}`
+
+func forbidden(w http.ResponseWriter, message string) {
+	quoted, _ := jsoniter.MarshalToString(message)
+	mug.Error(w, fmt.Sprintf(forbiddenPayload, quoted), http.StatusForbidden)
+}
+
+// authorize checks the authenticated payload against the route's requirement and its own Authorize,
+// answering 403 when it's not allowed.
+func authorize(w http.ResponseWriter, r *http.Request, payload any, req Requirement) bool {
+	// MakeRoute refuses requirements on inputs that aren't Permissioned
+	if holder, ok := payload.(mug.Permissioned); ok && !req.empty() {
+		if missing := req.missing(holder.Permissions()); missing != "" {
+			forbidden(w, "Missing "+missing+".")
+			return false
+		}
+	}
+
+	if authorizer, ok := payload.(mug.Authorizer); ok {
+		if err := authorizer.Authorize(r); err != nil {
+			forbidden(w, err.Error())
+			return false
+		}
+	}
+	return true
+}
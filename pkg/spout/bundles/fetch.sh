@@ -0,0 +1,14 @@
+#!/bin/sh
+# Vendors the ReDoc and Scalar bundles embedded by spout, at the versions pinned in swagger.go:
+#
+#	go generate ./pkg/spout
+set -eu
+cd "$(dirname "$0")"
+version() {
+	sed -n "s/^[[:space:]]*$1[[:space:]]*=[[:space:]]*\"\(.*\)\"/\1/p" ../swagger.go
+}
+redoc=$(version redocVersion)
+scalar=$(version scalarVersion)
+test -n "$redoc" && test -n "$scalar"
+curl -fsSL -o redoc.standalone.js "https://cdn.jsdelivr.net/npm/redoc@$redoc/bundles/redoc.standalone.js"
+curl -fsSL -o scalar.js "https://cdn.jsdelivr.net/npm/@scalar/api-reference@$scalar/dist/browser/standalone.js"
@@ -0,0 +1,118 @@
+package spout
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// loadOpenAPI generates the spec and loads it back, resolving its $refs
+// so it can be validated or used to validate responses.
+func loadOpenAPI() (*openapi3.T, error) {
+	data, err := generateOpenAPI("").MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return openapi3.NewLoader().LoadFromData(data)
+}
+
+// validateOpenAPI checks the generated spec, loudly reporting anything invalid.
+func validateOpenAPI() {
+	spec, err := loadOpenAPI()
+	if err == nil {
+		err = spec.Validate(context.Background())
+	}
+	if err != nil {
+		logger().Warn("the generated OpenAPI spec is invalid", "error", err)
+	}
+}
+
+// the spec used to check responses; reloaded when new routes are registered.
+var contract struct {
+	sync.Mutex
+	spec   *openapi3.T
+	routes int
+}
+
+func contractSpec() (*openapi3.T, error) {
+	contract.Lock()
+	defer contract.Unlock()
+
+	routes := len(registeredRoutes())
+	if contract.spec == nil || contract.routes != routes {
+		spec, err := loadOpenAPI()
+		if err != nil {
+			return nil, err
+		}
+		contract.spec, contract.routes = spec, routes
+	}
+	return contract.spec, nil
+}
+
+// checkContract wraps next, checking that its responses match what the spec
+// declares for the operation. Only meant for development: mismatches are logged, never answered.
+func checkContract(method, path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &teeRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if err := validateResponse(method, path, r, rec); err != nil {
+			logger().WarnContext(r.Context(), "response doesn't match the spec",
+				"method", method, "path", path, "status", rec.status, "error", err)
+		}
+	})
+}
+
+func validateResponse(method, path string, r *http.Request, rec *teeRecorder) error {
+	spec, err := contractSpec()
+	if err != nil {
+		return err
+	}
+	path, _ = pathParameters(path)
+	pathItem := spec.Paths.Find(path)
+	if pathItem == nil || pathItem.GetOperation(method) == nil {
+		return nil // not documented, nothing to compare with
+	}
+
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request: r,
+			Route: &routers.Route{
+				Spec:      spec,
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    method,
+				Operation: pathItem.GetOperation(method),
+			},
+		},
+		Status: rec.status,
+		Header: rec.Header(),
+		Body:   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true, // undeclared status codes are drift too
+		},
+	})
+}
+
+// teeRecorder writes the response through, keeping a copy of it.
+type teeRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (t *teeRecorder) WriteHeader(code int) {
+	t.status = code
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *teeRecorder) Write(b []byte) (int, error) {
+	t.body.Write(b)
+	return t.ResponseWriter.Write(b)
+}
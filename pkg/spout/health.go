@@ -0,0 +1,14 @@
+package spout
+
+import (
+	"net/http"
+
+	"github.com/sh-lucas/mug/pkg/health"
+)
+
+// ServeHealth serves the checks of pkg/health: the liveness ones at /healthz, all of them at /readyz.
+// Readiness also fails once the server is shutting down.
+func ServeHealth(r *http.ServeMux) {
+	r.Handle("GET /healthz", health.Handler(false))
+	r.Handle("GET /readyz", health.Handler(true))
+}
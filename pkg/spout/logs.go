@@ -0,0 +1,74 @@
+package spout
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg"
+	"github.com/sh-lucas/mug/pkg/brew"
+)
+
+// logs is read by every request, while SetLogger may replace it.
+var logs atomic.Pointer[slog.Logger]
+
+func init() {
+	logs.Store(pkg.NewLogger(os.Stderr))
+}
+
+func logger() *slog.Logger {
+	return logs.Load()
+}
+
+// SetLogger replaces the logger of spout, used for requests, panics and spec problems.
+func SetLogger(l *slog.Logger) {
+	logs.Store(l)
+}
+
+// logRequest logs an answered request at info level, warn for client errors and
+// error for server ones. It's left to brew.AccessLog when that's in the chain.
+func logRequest(r *http.Request, status int, start time.Time) {
+	if brew.AccessLogged(r.Context()) {
+		return
+	}
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+	logger().LogAttrs(r.Context(), level, "request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("pattern", r.Pattern),
+		slog.Int("status", status),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("request_id", brew.RequestIDFrom(r.Context())),
+	)
+}
+
+// statusRecorder keeps the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
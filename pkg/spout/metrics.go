@@ -0,0 +1,45 @@
+package spout
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg/metrics"
+)
+
+// labelled by the route's pattern, so /users/1 and /users/2 are the same series
+var (
+	requestsTotal = metrics.NewCounter("http_requests_total",
+		"Requests answered, by route and status code.", "route", "code")
+	requestDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"Time taken to answer requests, by route.", nil, "route")
+	requestsInFlight = metrics.NewGauge("http_requests_in_flight",
+		"Requests being answered, by route.", "route")
+)
+
+// ServeMetrics enables metrics and serves them at /metrics, in the Prometheus text format.
+func ServeMetrics(r *http.ServeMux) {
+	metrics.Enabled = true
+	r.Handle("GET /metrics", metrics.Handler())
+}
+
+// instrument records the requests of route, if metrics are enabled.
+func instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !metrics.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		requestsInFlight.Inc(route)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			requestsInFlight.Dec(route)
+			requestsTotal.Inc(route, strconv.Itoa(rec.status))
+			requestDuration.Observe(time.Since(start).Seconds(), route)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,266 @@
+package spout
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/invopop/jsonschema"
+)
+
+// schemas reflects go types into OpenAPI schemas.
+// Named types are declared once under components.schemas and referenced with $ref.
+type schemas struct {
+	reflector  jsonschema.Reflector
+	components openapi3.Schemas
+
+	names map[reflect.Type]string // type -> component name
+	taken map[string]reflect.Type // component name -> type
+
+	seen    map[reflect.Type]bool
+	clashes map[string][]reflect.Type // readable name -> the types collected under it
+}
+
+func newSchemas(components openapi3.Schemas) *schemas {
+	s := &schemas{
+		components: components,
+		names:      map[reflect.Type]string{},
+		taken:      map[string]reflect.Type{},
+		seen:       map[reflect.Type]bool{},
+		clashes:    map[string][]reflect.Type{},
+	}
+	s.reflector = jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		Anonymous:                 true, // no $id, it means nothing inside a spec
+		Namer:                     s.name,
+	}
+	return s
+}
+
+// schema returns the schema of t; a $ref if t is a named type.
+func (s *schemas) schema(t reflect.Type) *openapi3.SchemaRef {
+	root := s.reflector.ReflectFromType(t)
+	root.Version = "" // no $schema either
+
+	for name, def := range root.Definitions {
+		if _, ok := s.components[name]; !ok {
+			component := convert(def)
+			applyExamples(component, s.taken[name])
+			s.components[name] = component
+		}
+	}
+	root.Definitions = nil
+
+	schema := convert(root)
+	applyExamples(schema, t)
+	return schema
+}
+
+// applyExamples copies the `example:"..."` tags of t's fields into the schema's properties.
+// References are skipped, their component gets the examples of its own type.
+func applyExamples(schema *openapi3.SchemaRef, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || schema == nil || schema.Ref != "" || schema.Value == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		applyExamples(schema.Value.Items, t.Elem())
+	case reflect.Map:
+		if schema.Value.AdditionalProperties.Schema != nil {
+			applyExamples(schema.Value.AdditionalProperties.Schema, t.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			// embedded structs without a json name are flattened
+			if field.Anonymous && name == "" {
+				applyExamples(schema, field.Type)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			property := schema.Value.Properties[name]
+			if property == nil {
+				continue
+			}
+			if example, ok := field.Tag.Lookup("example"); ok && property.Ref == "" && property.Value != nil {
+				property.Value.Example = parseExample(example, field.Type)
+			}
+			applyExamples(property, field.Type)
+		}
+	}
+}
+
+// parseExample reads a tag's example as JSON (numbers, booleans, arrays...),
+// or as plain text for strings and anything that isn't valid JSON.
+func parseExample(example string, t reflect.Type) any {
+	if t.Kind() == reflect.String {
+		return example
+	}
+	var value any
+	if err := json.Unmarshal([]byte(example), &value); err != nil {
+		return example
+	}
+	return value
+}
+
+// convert turns a json schema into an OpenAPI one via JSON (preserves all fields),
+// pointing its references to the spec's components.
+func convert(schema *jsonschema.Schema) *openapi3.SchemaRef {
+	schemaBytes, _ := schema.MarshalJSON()
+	schemaBytes = bytes.ReplaceAll(schemaBytes, []byte(`"#/$defs/`), []byte(`"#/components/schemas/`))
+
+	var schemaRef openapi3.SchemaRef
+	_ = schemaRef.UnmarshalJSON(schemaBytes)
+	return &schemaRef
+}
+
+// collect walks the named types reachable from t before any is named,
+// so all the types of a clashing name are qualified alike, whatever the order of the routes.
+func (s *schemas) collect(t reflect.Type) {
+	if t == nil || s.seen[t] {
+		return
+	}
+	s.seen[t] = true
+	if t.Name() != "" && t.PkgPath() != "" {
+		name := readableName(t.Name())
+		s.clashes[name] = append(s.clashes[name], t)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		s.collect(t.Elem())
+	case reflect.Map:
+		s.collect(t.Key())
+		s.collect(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.IsExported() && field.Tag.Get("json") != "-" {
+				s.collect(field.Type)
+			}
+		}
+	}
+}
+
+// name gives each named type a readable and unique component name.
+// Generic types get their arguments appended, so JsonBody[user.PublishBody]
+// becomes JsonBody_PublishBody. Types sharing a name are all prefixed by the end
+// of their package path, as short as tells them apart: handlers.user.CreateUserInput and
+// v2.user.CreateUserInput. Whatever still clashes is numbered.
+func (s *schemas) name(t reflect.Type) string {
+	if t.Name() == "" {
+		return "" // anonymous types are inlined
+	}
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := readableName(t.Name())
+	if group := s.clashes[name]; len(group) > 1 && slices.Contains(group, t) {
+		name = qualified(name, t, group)
+	} else if other, ok := s.taken[name]; ok && other != t {
+		// a type collect didn't reach
+		if pkg := packageName(t); pkg != "" {
+			name = pkg + "." + name
+		}
+	}
+	base := name
+	for i := 2; s.taken[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	s.names[t] = name
+	s.taken[name] = t
+	return name
+}
+
+// matches the package path qualifying a type, e.g. "github.com/sh-lucas/mug/pkg/mug."
+var qualifier = regexp.MustCompile(`[\w\-.~]+(/[\w\-.~]+)*\.`)
+
+var notAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func readableName(typeName string) string {
+	base, args, generic := strings.Cut(typeName, "[")
+	if !generic {
+		return base
+	}
+
+	parts := []string{base}
+	for _, arg := range splitTypeArgs(strings.TrimSuffix(args, "]")) {
+		if strings.HasPrefix(arg, "struct") {
+			parts = append(parts, "Anonymous")
+			continue
+		}
+		arg = qualifier.ReplaceAllString(arg, "")
+		arg = strings.Trim(notAlphanumeric.ReplaceAllString(arg, "_"), "_")
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, "_")
+}
+
+// splitTypeArgs splits type arguments on the commas outside of brackets, braces and quotes.
+func splitTypeArgs(args string) (split []string) {
+	depth, start := 0, 0
+	quoted := false
+	for i, r := range args {
+		switch {
+		case r == '"' && (i == 0 || args[i-1] != '\\'):
+			quoted = !quoted
+		case quoted:
+		case r == '[' || r == '{' || r == '(':
+			depth++
+		case r == ']' || r == '}' || r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			split = append(split, strings.TrimSpace(args[start:i]))
+			start = i + 1
+		}
+	}
+	return append(split, strings.TrimSpace(args[start:]))
+}
+
+// qualified prefixes name with the fewest trailing elements of t's package path
+// that set apart the types of group.
+func qualified(name string, t reflect.Type, group []reflect.Type) string {
+	prefix := func(t reflect.Type, n int) string {
+		elements := strings.Split(t.PkgPath(), "/")
+		return strings.Join(elements[max(0, len(elements)-n):], ".")
+	}
+	longest := 0
+	for _, other := range group {
+		longest = max(longest, strings.Count(other.PkgPath(), "/")+1)
+	}
+
+	n := 1
+	for ; n < longest; n++ {
+		distinct := map[string]bool{}
+		for _, other := range group {
+			distinct[prefix(other, n)] = true
+		}
+		if len(distinct) == len(group) {
+			break
+		}
+	}
+	return prefix(t, n) + "." + name
+}
+
+func packageName(t reflect.Type) string {
+	path := t.PkgPath()
+	return path[strings.LastIndex(path, "/")+1:]
+}
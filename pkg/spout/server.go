@@ -0,0 +1,118 @@
+package spout
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg/health"
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+// ServerConfig configures the server of the generated router, from the server section of mug.yml.
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// time /readyz fails before the server stops accepting connections,
+	// so load balancers stop sending traffic first
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// time in-flight requests get to finish after SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// served with TLS when both are set
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// path of a unix socket to listen on, instead of the TCP address
+	Socket string `yaml:"socket"`
+}
+
+// NewServer returns a server for h at addr with the timeouts of cfg.
+// Customise it before handing it to Serve.
+func NewServer(addr string, h http.Handler, cfg ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// Serve listens on cfg.Socket, or on srv.Addr, with TLS if cfg has a certificate.
+// On SIGINT or SIGTERM it fails readiness for cfg.ShutdownDelay, stops accepting connections
+// and waits up to cfg.ShutdownTimeout for the requests in flight, returning nil once they're done.
+// It fails before listening if the session configuration is invalid, see mug.SetupSessions.
+func Serve(srv *http.Server, cfg ServerConfig) error {
+	return ServeContext(context.Background(), srv, cfg)
+}
+
+// ServeContext works like Serve, also shutting down when ctx is done.
+func ServeContext(ctx context.Context, srv *http.Server, cfg ServerConfig) error {
+	// a bad MUG_SESSION_* env stops the app before it listens
+	if err := mug.SetupSessions(); err != nil {
+		return err
+	}
+	ln, err := listen(srv.Addr, cfg.Socket)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	health.MarkServing()
+
+	served := make(chan error, 1)
+	go func() {
+		if cfg.CertFile != "" && cfg.KeyFile != "" {
+			served <- srv.ServeTLS(ln, cfg.CertFile, cfg.KeyFile)
+		} else {
+			served <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	health.MarkShuttingDown()
+	if cfg.ShutdownDelay > 0 {
+		logger().Info("shutting down, failing readiness", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	logger().Info("shutting down, draining requests", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx := context.Background()
+	if cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, cfg.ShutdownTimeout)
+		defer cancel()
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func listen(addr, socket string) (net.Listener, error) {
+	if socket == "" {
+		return net.Listen("tcp", addr)
+	}
+	// a socket left behind by a crash would make it fail
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen("unix", socket)
+}
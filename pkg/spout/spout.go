@@ -3,10 +3,12 @@ package spout
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -14,6 +16,7 @@ import (
 	en_translations "github.com/go-playground/validator/v10/translations/en"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/sh-lucas/mug/pkg"
+	"github.com/sh-lucas/mug/pkg/brew"
 	"github.com/sh-lucas/mug/pkg/mug"
 )
 
@@ -30,6 +33,10 @@ func init() {
 	// setup validator json parser
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "" {
+			// fields of form bodies
+			name = fld.Tag.Get("form")
+		}
 		if name == "-" {
 			return ""
 		}
@@ -46,27 +53,58 @@ var internalErrorMsg = `{
 	"message": "The issue must be reported to the system administrator."
 }`
 
+// Meta documents a handler in the OpenAPI spec.
+// mug fills it from the handler's doc comment and its // mug: annotations.
+type Meta struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+	Responses   []Response
+
+	// JSON examples, usually read from the handler's examples folder
+	RequestExample   string
+	ResponseExamples map[int]string
+
+	// the API version the route belongs to, see Versions
+	Version string
+
+	// the roles and scopes needed, checked after authentication
+	Require Requirement
+}
+
+// Response is a status code the handler may answer with.
+// Its body is the handler's output type.
+type Response struct {
+	Code        int
+	Description string
+}
+
+// ErrorBody is the body of the errors answered by mug itself, like authentication failures.
+type ErrorBody struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the body answered when the input is invalid; it maps each field to its error.
+type ValidationErrors map[string]string
+
 // Defines a new kegHandler in r (router), at path, with middlewares before handler.
 func MakeHandler[T any, U any](
 	r *http.ServeMux,
 	path string, handler func(input T) (code int, body U),
 	middlewares ...middleware,
 ) {
-	chained := chain(middlewares, ConvertHandler(handler))
-
-	r.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		// crash recovery
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf(pkg.Red+"panic: %v\n"+pkg.Reset, r)
-				http.Error(w, internalErrorMsg, 500)
-				return
-			}
-		}()
-		chained.ServeHTTP(w, r)
-	})
+	MakeRoute(r, path, Meta{}, handler, middlewares...)
+}
 
-	// Register for Swagger
+// MakeRoute works like MakeHandler, also documenting the route with meta.
+func MakeRoute[T any, U any](
+	r *http.ServeMux,
+	path string, meta Meta, handler func(input T) (code int, body U),
+	middlewares ...middleware,
+) {
 	parts := strings.SplitN(path, " ", 2)
 	method := "GET"
 	url := path
@@ -75,12 +113,63 @@ func MakeHandler[T any, U any](
 		url = parts[1]
 	}
 
-	registry = append(registry, RouteSpec{
+	if !meta.Require.empty() {
+		if _, ok := any(new(T)).(mug.Permissioned); !ok {
+			panic(fmt.Sprintf("spout: %s requires %s, but %s has no auth mixin", path, meta.Require, reflect.TypeFor[T]()))
+		}
+	}
+
+	chained := chain(middlewares, authorizedHandler(handler, meta.Require))
+	// in development, responses are checked against the spec
+	if pkg.Dev() {
+		chained = checkContract(method, url, chained)
+	}
+	if meta.Version != "" {
+		chained = Versioned(meta.Version, chained)
+	}
+
+	handle(r, path, method, url, chained)
+
+	// Register for Swagger
+	register(RouteSpec{
 		Method:     method,
 		Path:       url,
 		InputType:  reflect.TypeOf((*T)(nil)).Elem(),
 		OutputType: reflect.TypeOf((*U)(nil)).Elem(),
+		Meta:       meta,
+	})
+}
+
+// Handle registers a plain handler at pattern, with the panic recovery, request
+// logs, metrics and tracing the routes made by MakeRoute get.
+func Handle(r *http.ServeMux, pattern string, h http.Handler) {
+	method, url, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, url = "", pattern
+	}
+	handle(r, pattern, method, url, h)
+}
+
+func handle(r *http.ServeMux, pattern, method, url string, h http.Handler) {
+	served := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// crash recovery
+		defer func() {
+			if err := recover(); err != nil {
+				logger().LogAttrs(r.Context(), slog.LevelError, "panic recovered",
+					slog.String("panic", fmt.Sprint(err)),
+					slog.String("pattern", r.Pattern),
+					slog.String("request_id", brew.RequestIDFrom(r.Context())),
+					slog.String("stack", string(debug.Stack())),
+				)
+				mug.Error(rec, internalErrorMsg, 500)
+			}
+			logRequest(r, rec.status, start)
+		}()
+		h.ServeHTTP(rec, r)
 	})
+	r.Handle(pattern, instrument(pattern, traced(method, url, served)))
 }
 
 // chain chains middlewares before a finalHandler.
@@ -103,8 +192,22 @@ func chain(middlewares []middleware, finalHandler http.Handler) http.Handler {
 
 // converts a personalized handler (kegHandler) to an http.Handler
 func ConvertHandler[T any, U any](handler kegHandler[T, U]) http.Handler {
+	return authorizedHandler(handler, Requirement{})
+}
+
+// authorizedHandler works like ConvertHandler, also authorizing the requests as require says.
+func authorizedHandler[T any, U any](handler kegHandler[T, U], require Requirement) http.Handler {
+	// a form body that can't be bound fails here, when the route is made
+	mug.CheckForm(new(T))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
+		// the files streamed to disk go once the handler is done,
+		// whether the form was parsed for the body or for its CSRF token
+		defer func() {
+			if r.MultipartForm != nil {
+				r.MultipartForm.RemoveAll()
+			}
+		}()
 		// unmarshal into T and check if something is missing.
 		// errors are ignored because of the validation that do it's job.
 		var payload T
@@ -115,9 +218,22 @@ func ConvertHandler[T any, U any](handler kegHandler[T, U]) http.Handler {
 				return
 			}
 		}
+		if !authorize(w, r, &payload, require) {
+			return
+		}
+
+		// Check for Contextable interface (hands the request's context, with its span, to the handler)
+		if contextable, ok := any(&payload).(mug.Contextable); ok {
+			contextable.SetContext(r.Context())
+		}
 
-		// Check for Bodyable interface (handles custom body parsing)
-		if bodyRouter, ok := any(&payload).(mug.Bodyable); ok {
+		// Check for BodyDecoder interface (mixins reading forms and files themselves)
+		if decoder, ok := any(&payload).(mug.BodyDecoder); ok {
+			if !decoder.DecodeBody(w, r) {
+				return
+			}
+		} else if bodyRouter, ok := any(&payload).(mug.Bodyable); ok {
+			// Check for Bodyable interface (handles custom body parsing)
 			// Decodes body into the struct pointer returned by GetBodyPtr()
 			if err := jsoniter.NewDecoder(r.Body).Decode(bodyRouter.GetBodyPtr()); err != nil {
 				// We don't error out here immediately, let validation handle it
@@ -132,7 +248,9 @@ func ConvertHandler[T any, U any](handler kegHandler[T, U]) http.Handler {
 		err := validate.Struct(&payload)
 		if err != nil {
 			errMsg := formatValidationErrors(err, translator)
-			http.Error(w, string(errMsg), http.StatusBadRequest)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(errMsg)
 			return
 		}
 
@@ -142,8 +260,8 @@ func ConvertHandler[T any, U any](handler kegHandler[T, U]) http.Handler {
 		// marshal response
 		err = jsoniter.NewEncoder(w).Encode(body)
 		if err != nil {
-			log.Println("Unsmarshable content returned from handler!")
-			http.Error(w, internalErrorMsg, http.StatusInternalServerError)
+			logger().ErrorContext(r.Context(), "handler returned a body that can't be marshalled", "error", err)
+			mug.Error(w, internalErrorMsg, http.StatusInternalServerError)
 			return
 		}
 	})
@@ -153,7 +271,7 @@ func ConvertHandler[T any, U any](handler kegHandler[T, U]) http.Handler {
 // so your api is easy to consume.
 func formatValidationErrors(err error, trans ut.Translator) []byte {
 
-	response := make(map[string]string)
+	response := make(ValidationErrors)
 	var validationErrors validator.ValidationErrors
 
 	if errors.As(err, &validationErrors) {
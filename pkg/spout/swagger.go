@@ -1,53 +1,244 @@
 package spout
 
 import (
-	_ "embed"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"maps"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/getkin/kin-openapi/openapi3"
-	"github.com/invopop/jsonschema"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/sh-lucas/mug/pkg/mug"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"gopkg.in/yaml.v3"
 )
 
 // Swagger Implementation
 
 type RouteSpec struct {
-	Method      string
-	Path        string
-	InputType   reflect.Type
-	OutputType  reflect.Type
-	Summary     string
+	Method     string
+	Path       string
+	InputType  reflect.Type
+	OutputType reflect.Type
+	Meta
+}
+
+// the routes registered by MakeRoute; the docs read them while routes may still be registered.
+var registry struct {
+	sync.RWMutex
+	routes []RouteSpec
+}
+
+func register(route RouteSpec) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.routes = append(registry.routes, route)
+}
+
+// registeredRoutes returns a copy of the registered routes.
+func registeredRoutes() []RouteSpec {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Clone(registry.routes)
+}
+
+// Reset forgets the registered routes, so registering them again,
+// e.g. on a new mux, doesn't document them twice.
+func Reset() {
+	registry.Lock()
+	registry.routes = nil
+	registry.Unlock()
+
+	contract.Lock()
+	defer contract.Unlock()
+	contract.spec = nil
+}
+
+// DocsConfig sets what the spec says about the API and how its docs are served.
+// The generated router fills it from the openapi section of mug.yml.
+type DocsConfig struct {
+	Title     string
+	Version   string
+	Servers   []Server
+	Renderer  string // "swagger" (default), "redoc" or "scalar"
+	AssetsDir string // a folder with extra files for /docs/assets, e.g. redoc.standalone.js
+}
+
+type Server struct {
+	URL         string
 	Description string
 }
 
-var registry []RouteSpec
+var Docs = DocsConfig{
+	Title:    "Mug API",
+	Version:  "1.0.0",
+	Renderer: "swagger",
+}
+
+//go:embed *.template.html
+var docsTemplates embed.FS
+
+var docsPages = template.Must(template.ParseFS(docsTemplates, "*.template.html"))
+
+// the ReDoc and Scalar versions embedded from ./bundles; bundles/fetch.sh reads them from here
+const (
+	redocVersion  = "2.5.0"
+	scalarVersion = "1.25.0"
+)
 
-//go:embed swagger.template.html
-var swaggerTemplate []byte
+//go:generate sh bundles/fetch.sh
+//go:embed bundles
+var bundles embed.FS
 
-// ServeDocs serves the Swagger UI and the generated OpenAPI spec.
+// rendererBundles are the bundles ReDoc and Scalar need, embedded or in AssetsDir.
+var rendererBundles = map[string]string{
+	"redoc":  "redoc.standalone.js",
+	"scalar": "scalar.js",
+}
+
+type docsPage struct {
+	Title   string
+	SpecURL string
+	Assets  string
+}
+
+// ServeDocs serves the docs UI, its assets and the generated OpenAPI spec.
+// Swagger UI, ReDoc and Scalar are embedded, so /docs works offline; a renderer whose
+// bundle is missing from the build and from AssetsDir falls back to Swagger UI.
+// Each API version also gets its own spec and docs, e.g. /v1/swagger.json and /v1/docs.
+// The spec is validated right away; problems are logged, not fatal.
 func ServeDocs(r *http.ServeMux) {
+	validateOpenAPI()
+	renderer := Docs.Renderer
+	if bundle, ok := rendererBundles[renderer]; ok && !vendoredAsset(bundle) {
+		logger().Warn("the "+renderer+" bundle is missing, /docs falls back to Swagger UI; run go generate ./pkg/spout or put it in the assets folder", "asset", bundle)
+		renderer = "swagger"
+	}
+
+	serveSpec(r, "", renderer)
+	for _, version := range apiVersions() {
+		serveSpec(r, version, renderer)
+	}
+
+	r.Handle("GET /docs/assets/", http.StripPrefix("/docs/assets/", docsAssets()))
+}
+
+// serveSpec serves the spec and the docs UI of version, or of every route if version is "".
+func serveSpec(r *http.ServeMux, version, renderer string) {
+	prefix := ""
+	title := Docs.Title
+	if version != "" {
+		prefix = "/" + version
+		title += " " + version
+	}
+
 	// Serve swagger.json
-	r.HandleFunc("GET /swagger.json", func(w http.ResponseWriter, r *http.Request) {
-		spec := generateOpenAPI()
+	r.HandleFunc("GET "+prefix+"/swagger.json", func(w http.ResponseWriter, r *http.Request) {
+		spec := generateOpenAPI(version)
+		w.Header().Set("Content-Type", "application/json")
 		jsoniter.NewEncoder(w).Encode(spec)
 	})
 
-	// Serve Swagger UI
-	r.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		w.Write(swaggerTemplate)
+	// Serve the UI
+	page := renderer + ".template.html"
+	if docsPages.Lookup(page) == nil {
+		page = "swagger.template.html"
+	}
+	r.HandleFunc("GET "+prefix+"/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsPages.ExecuteTemplate(w, page, docsPage{
+			Title:   title,
+			SpecURL: prefix + "/swagger.json",
+			Assets:  "/docs/assets",
+		})
+	})
+}
+
+// vendoredAsset tells whether the bundle name was embedded, or is in AssetsDir.
+func vendoredAsset(name string) bool {
+	if _, err := fs.Stat(bundles, "bundles/"+name); err == nil {
+		return true
+	}
+	if Docs.AssetsDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(Docs.AssetsDir, name))
+	return err == nil
+}
+
+// docsAssets serves the files in AssetsDir first, then the embedded ReDoc, Scalar and Swagger UI.
+func docsAssets() http.Handler {
+	embedded := http.FileServerFS(swaggerFiles.FS)
+	vendored, _ := fs.Sub(bundles, "bundles")
+	bundled := http.FileServerFS(vendored)
+	var local http.Handler
+	if Docs.AssetsDir != "" {
+		local = http.FileServer(http.Dir(Docs.AssetsDir))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if local != nil {
+			if _, err := os.Stat(filepath.Join(Docs.AssetsDir, filepath.FromSlash(name))); err == nil {
+				local.ServeHTTP(w, r)
+				return
+			}
+		}
+		if _, err := fs.Stat(vendored, strings.TrimPrefix(name, "/")); err == nil {
+			bundled.ServeHTTP(w, r)
+			return
+		}
+		embedded.ServeHTTP(w, r)
 	})
 }
 
-func generateOpenAPI() *openapi3.T {
+// WriteOpenAPI writes the spec of every registered handler to w,
+// formatted as "json" or "yaml". Used by `mug openapi` to export it without serving.
+func WriteOpenAPI(w io.Writer, format string) error {
+	spec := generateOpenAPI("")
+
+	switch strings.ToLower(format) {
+	case "json":
+		// indented and key-sorted, so the output is stable and diffable
+		out, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(out, '\n'))
+		return err
+	case "yaml", "yml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(spec); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unknown openapi format %q, use json or yaml", format)
+	}
+}
+
+// generateOpenAPI builds the spec of the routes of version, or of every route if version is "".
+func generateOpenAPI(version string) *openapi3.T {
 	spec := &openapi3.T{
 		OpenAPI: "3.0.0",
 		Info: &openapi3.Info{
-			Title:   "Mug API",
-			Version: "1.0.0",
+			Title:   Docs.Title,
+			Version: Docs.Version,
 		},
 		Paths: &openapi3.Paths{},
 		Components: &openapi3.Components{
@@ -55,114 +246,78 @@ func generateOpenAPI() *openapi3.T {
 		},
 	}
 
-	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
+	for _, server := range Docs.Servers {
+		spec.AddServer(&openapi3.Server{URL: server.URL, Description: server.Description})
 	}
 
-	for _, route := range registry {
-		// Try to extract actual body type from JsonBodyT
-		actualBodyType := extractBodyType(route.InputType)
-
-		// Build request body
-		var requestBody *openapi3.RequestBodyRef
-		if actualBodyType != nil {
-			// Generate schema for the extracted body type
-			bodySchema := reflector.ReflectFromType(actualBodyType)
+	routes := registeredRoutes()
+	schemas := newSchemas(spec.Components.Schemas)
+	for _, route := range routes {
+		if version == "" || route.Version == version {
+			schemas.collect(route.InputType)
+			schemas.collect(route.OutputType)
+		}
+	}
 
-			// Convert to OpenAPI schema via JSON (preserves all fields)
-			schemaBytes, _ := bodySchema.MarshalJSON()
-			var schemaRef openapi3.SchemaRef
-			_ = schemaRef.UnmarshalJSON(schemaBytes)
+	for _, route := range routes {
+		if version != "" && route.Version != version {
+			continue
+		}
 
-			requestBody = &openapi3.RequestBodyRef{
-				Value: &openapi3.RequestBody{
-					Content: openapi3.Content{
-						"application/json": &openapi3.MediaType{
-							Schema: &schemaRef,
-						},
-					},
-				},
+		// Build request body: the JsonBody's type, or the whole
+		// input as a fallback for single structs
+		var requestBody *openapi3.RequestBodyRef
+		bodyType := extractBodyType(route.InputType)
+		if bodyType == nil && route.InputType.Kind() == reflect.Struct {
+			bodyType = route.InputType
+		}
+		if bodyType != nil {
+			content := jsonContent(schemas.schema(bodyType))
+			if decoder, ok := reflect.New(route.InputType).Interface().(mug.BodyDecoder); ok {
+				content = formContent(schemas, decoder.ContentType(), bodyType)
 			}
-		} else if route.InputType.Kind() == reflect.Struct {
-			// Fallback: use the input type directly if no JsonBodyT found
-			inputSchema := reflector.ReflectFromType(route.InputType)
-
-			// Convert to OpenAPI schema via JSON (preserves all fields)
-			schemaBytes, _ := inputSchema.MarshalJSON()
-			var schemaRef openapi3.SchemaRef
-			_ = schemaRef.UnmarshalJSON(schemaBytes)
-
 			requestBody = &openapi3.RequestBodyRef{
 				Value: &openapi3.RequestBody{
-					Content: openapi3.Content{
-						"application/json": &openapi3.MediaType{
-							Schema: &schemaRef,
-						},
-					},
+					Content: withExample(content, exampleOf(route.RequestExample, bodyType)),
 				},
 			}
 		}
 
 		// Build responses
-		responses := &openapi3.Responses{}
-		if route.OutputType.Kind() == reflect.Struct {
-			// Generate inline schema for output
-			outputSchema := reflector.ReflectFromType(route.OutputType)
-
-			// Convert to OpenAPI schema via JSON (preserves all fields)
-			schemaBytes, _ := outputSchema.MarshalJSON()
-			var schemaRef openapi3.SchemaRef
-			_ = schemaRef.UnmarshalJSON(schemaBytes)
-
-			responses.Set("200", &openapi3.ResponseRef{
-				Value: &openapi3.Response{
-					Description: ptr("Successful response"),
-					Content: openapi3.Content{
-						"application/json": &openapi3.MediaType{
-							Schema: &schemaRef,
-						},
-					},
-				},
-			})
-		} else if route.OutputType.Kind() == reflect.Interface {
-			// For interface{} outputs, just show generic response
-			responses.Set("200", &openapi3.ResponseRef{
-				Value: &openapi3.Response{
-					Description: ptr("Successful response"),
-					Content: openapi3.Content{
-						"application/json": &openapi3.MediaType{
-							Schema: &openapi3.SchemaRef{
-								Value: &openapi3.Schema{
-									Type: &openapi3.Types{"object"},
-								},
-							},
-						},
-					},
-				},
-			})
-		} else {
-			// Default response if no struct
-			responses.Set("200", &openapi3.ResponseRef{
-				Value: &openapi3.Response{
-					Description: ptr("Successful response"),
-				},
-			})
-		}
+		responses := buildResponses(schemas, route)
 
 		// Create operation
 		op := &openapi3.Operation{
 			Summary:     route.Summary,
 			Description: route.Description,
+			Tags:        route.Tags,
+			OperationID: route.OperationID,
+			Deprecated:  route.Deprecated || Versions[route.Version].Deprecated,
 			RequestBody: requestBody,
 			Responses:   responses,
 		}
 
+		op.Security = securityOf(spec, route.InputType)
+		if !route.Require.empty() {
+			op.Description = strings.TrimSpace(op.Description + "\n\nRequires " + route.Require.String() + ".")
+			require := map[string][]string{}
+			if len(route.Require.Roles) > 0 {
+				require["roles"] = route.Require.Roles
+			}
+			if len(route.Require.Scopes) > 0 {
+				require["scopes"] = route.Require.Scopes
+			}
+			op.Extensions = map[string]any{"x-require": require}
+		}
+
+		path, parameters := pathParameters(route.Path)
+		op.Parameters = parameters
+
 		// Get or create path item
-		pathItem := spec.Paths.Find(route.Path)
+		pathItem := spec.Paths.Find(path)
 		if pathItem == nil {
 			pathItem = &openapi3.PathItem{}
-			spec.Paths.Set(route.Path, pathItem)
+			spec.Paths.Set(path, pathItem)
 		}
 
 		// Assign operation to the correct method
@@ -183,6 +338,223 @@ func generateOpenAPI() *openapi3.T {
 	return spec
 }
 
+// buildResponses documents every status the route may answer with:
+// the ones declared on the handler (or a default 200), the validation
+// error and the errors answered by the input's mixins, like authentication.
+func buildResponses(schemas *schemas, route RouteSpec) *openapi3.Responses {
+	responses := &openapi3.Responses{}
+	output := outputContent(schemas, route.OutputType)
+
+	declared := route.Responses
+	if !slices.ContainsFunc(declared, func(r Response) bool { return r.Code < 400 }) {
+		declared = append([]Response{{Code: http.StatusOK, Description: "Successful response"}}, declared...)
+	}
+	// the handler returns the same type whatever the code
+	for _, r := range declared {
+		var example any
+		if raw, ok := route.ResponseExamples[r.Code]; ok {
+			example = exampleOf(raw, nil)
+		} else if r.Code < 400 {
+			example = exampleOf("", route.OutputType)
+		}
+		responses.Set(strconv.Itoa(r.Code), &openapi3.ResponseRef{
+			Value: &openapi3.Response{
+				Description: ptr(describe(r.Code, r.Description)),
+				Content:     withExample(output, example),
+			},
+		})
+	}
+
+	// errors answered by mug itself, unless the handler declared its own
+	errorBody := jsonContent(schemas.schema(reflect.TypeOf(ErrorBody{})))
+	setDefault := func(code int, description string, content openapi3.Content) {
+		if responses.Value(strconv.Itoa(code)) == nil {
+			responses.Set(strconv.Itoa(code), &openapi3.ResponseRef{
+				Value: &openapi3.Response{Description: ptr(description), Content: content},
+			})
+		}
+	}
+
+	if route.InputType.Kind() == reflect.Struct {
+		validation := schemas.schema(reflect.TypeOf(ValidationErrors{}))
+		setDefault(http.StatusBadRequest, "Invalid input; the body maps each field to its error", jsonContent(validation))
+
+		if _, ok := reflect.New(route.InputType).Interface().(mug.BodyDecoder); ok {
+			setDefault(http.StatusRequestEntityTooLarge, "The body is too large", errorBody)
+			setDefault(http.StatusUnsupportedMediaType, "The body is not a form", errorBody)
+		}
+		if !route.Require.empty() {
+			setDefault(http.StatusForbidden, "Requires "+route.Require.String(), errorBody)
+		}
+		if describer, ok := reflect.New(route.InputType).Interface().(mug.ErrorDescriber); ok {
+			errs := describer.ErrorResponses()
+			codes := slices.Sorted(maps.Keys(errs))
+			for _, code := range codes {
+				setDefault(code, errs[code], errorBody)
+			}
+		}
+	}
+
+	return responses
+}
+
+// securityOf declares the security schemes of the input's auth mixins
+// under the spec's components and returns the operation's requirements.
+func securityOf(spec *openapi3.T, input reflect.Type) *openapi3.SecurityRequirements {
+	if input.Kind() != reflect.Struct {
+		return nil
+	}
+	describer, ok := reflect.New(input).Interface().(mug.SecurityDescriber)
+	if !ok {
+		return nil
+	}
+
+	if spec.Components.SecuritySchemes == nil {
+		spec.Components.SecuritySchemes = openapi3.SecuritySchemes{}
+	}
+	requirements := openapi3.NewSecurityRequirements()
+	for _, scheme := range describer.SecuritySchemes() {
+		spec.Components.SecuritySchemes[scheme.Name] = &openapi3.SecuritySchemeRef{
+			Value: &openapi3.SecurityScheme{
+				Type:         scheme.Type,
+				Scheme:       scheme.Scheme,
+				BearerFormat: scheme.BearerFormat,
+				In:           scheme.In,
+				Name:         scheme.ParamName,
+				Description:  scheme.Description,
+			},
+		}
+		// each requirement is an alternative; any of them authenticates
+		requirements.With(openapi3.NewSecurityRequirement().Authenticate(scheme.Name))
+	}
+	return requirements
+}
+
+// outputContent is the content returned by a handler with output type t.
+func outputContent(schemas *schemas, t reflect.Type) openapi3.Content {
+	switch t.Kind() {
+	case reflect.Struct:
+		return jsonContent(schemas.schema(t))
+	case reflect.Interface:
+		// For interface{} outputs, just show generic response
+		return jsonContent(&openapi3.SchemaRef{
+			Value: &openapi3.Schema{Type: &openapi3.Types{"object"}},
+		})
+	default:
+		return nil
+	}
+}
+
+// exampler is implemented by inputs and outputs that can show how they look.
+type exampler interface {
+	Example() any
+}
+
+// exampleOf prefers the raw JSON example (from the examples folder),
+// falling back to the Example() method of t.
+func exampleOf(raw string, t reflect.Type) any {
+	if raw != "" {
+		var example any
+		if err := json.Unmarshal([]byte(raw), &example); err == nil {
+			return example
+		}
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	if e, ok := reflect.New(t).Interface().(exampler); ok {
+		return e.Example()
+	}
+	return nil
+}
+
+// withExample copies content, setting the example of each media type.
+func withExample(content openapi3.Content, example any) openapi3.Content {
+	if example == nil || content == nil {
+		return content
+	}
+	copied := openapi3.Content{}
+	for mime, media := range content {
+		withExample := *media
+		withExample.Example = example
+		copied[mime] = &withExample
+	}
+	return copied
+}
+
+func jsonContent(schema *openapi3.SchemaRef) openapi3.Content {
+	return openapi3.Content{
+		"application/json": &openapi3.MediaType{Schema: schema},
+	}
+}
+
+// matches the wildcards of mux patterns: {id}, {path...} and {$}
+var wildcard = regexp.MustCompile(`\{([^}]*)\}`)
+
+// pathParameters turns a mux pattern's path into an OpenAPI one, {path...} becoming {path}
+// and {$} dropped, along with a required string parameter for each wildcard.
+func pathParameters(pattern string) (string, openapi3.Parameters) {
+	var parameters openapi3.Parameters
+	path := wildcard.ReplaceAllStringFunc(pattern, func(match string) string {
+		name := strings.TrimSuffix(strings.Trim(match, "{}"), "...")
+		if name == "$" {
+			return ""
+		}
+		parameters = append(parameters, &openapi3.ParameterRef{
+			Value: openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema()),
+		})
+		return "{" + name + "}"
+	})
+	return path, parameters
+}
+
+// formContent documents a form body by the form names of its fields, files as binary strings
+// with the types they accept.
+func formContent(schemas *schemas, mediaType string, t reflect.Type) openapi3.Content {
+	schema := openapi3.NewObjectSchema()
+	media := &openapi3.MediaType{}
+	binary := openapi3.NewStringSchema().WithFormat("binary")
+	for _, field := range reflect.VisibleFields(t) {
+		name, ok := mug.FormName(field)
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case reflect.TypeFor[*multipart.FileHeader]():
+			schema.WithPropertyRef(name, binary.NewRef())
+		case reflect.TypeFor[[]*multipart.FileHeader]():
+			schema.WithPropertyRef(name, openapi3.NewArraySchema().WithItems(binary).NewRef())
+		default:
+			schema.WithPropertyRef(name, schemas.schema(field.Type))
+		}
+		if accept := field.Tag.Get("accept"); accept != "" {
+			if media.Encoding == nil {
+				media.Encoding = map[string]*openapi3.Encoding{}
+			}
+			media.Encoding[name] = &openapi3.Encoding{ContentType: strings.ReplaceAll(accept, " ", "")}
+		}
+		if slices.Contains(strings.Split(field.Tag.Get("validate"), ","), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	media.Schema = schema.NewRef()
+	return openapi3.Content{mediaType: media}
+}
+
+// describe falls back to the status text when there's no description.
+func describe(code int, description string) string {
+	if description != "" {
+		return description
+	}
+	if text := http.StatusText(code); text != "" {
+		return text
+	}
+	return "Response " + strconv.Itoa(code)
+}
+
 // extractBodyType attempts to extract the type parameter from JsonBodyT[T]
 func extractBodyType(t reflect.Type) reflect.Type {
 	if t == nil || t.Kind() != reflect.Struct {
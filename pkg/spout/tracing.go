@@ -0,0 +1,47 @@
+package spout
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of the global provider, a no-op until tracing.Setup installs one.
+// It's looked up each time, so replacing the provider (e.g. in tests) takes effect.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/sh-lucas/mug/pkg/spout")
+}
+
+// traced answers each request of route inside a server span, child of the
+// traceparent sent by the client if any. Handlers reach it with the mug.Context mixin.
+// The method is empty for routes answering any of them.
+func traced(method, route string, next http.Handler) http.Handler {
+	name := route
+	if method != "" {
+		name = method + " " + route
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
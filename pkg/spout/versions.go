@@ -0,0 +1,56 @@
+package spout
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// APIVersion describes a version of the API, whose routes are mounted under /<name>.
+type APIVersion struct {
+	Deprecated   bool
+	DeprecatedAt time.Time // when it was deprecated, if known
+	Sunset       time.Time // when it stops being served, if known
+}
+
+// Versions are the versions of the API, by name.
+// The generated router fills it from the handlers/v2 folders and // mug:version annotations.
+var Versions = map[string]APIVersion{}
+
+// Versioned sends the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+// on every response of next, if version is deprecated.
+func Versioned(version string, next http.Handler) http.Handler {
+	v := Versions[version]
+	if !v.Deprecated {
+		return next
+	}
+
+	deprecation := "true"
+	if !v.DeprecatedAt.IsZero() {
+		deprecation = fmt.Sprintf("@%d", v.DeprecatedAt.Unix())
+	}
+	sunset := ""
+	if !v.Sunset.IsZero() {
+		sunset = v.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiVersions lists the versions of the registered routes, sorted.
+func apiVersions() (versions []string) {
+	for _, route := range registeredRoutes() {
+		if route.Version != "" && !slices.Contains(versions, route.Version) {
+			versions = append(versions, route.Version)
+		}
+	}
+	slices.Sort(versions)
+	return versions
+}
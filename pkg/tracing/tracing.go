@@ -0,0 +1,73 @@
+// Package tracing sets up OpenTelemetry, so the spans spout and rabbit start are exported.
+// Without Setup they're no-ops, but the W3C traceparent of incoming requests and
+// messages is still propagated once a propagator is installed.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Setup installs the global tracer provider and the W3C propagators (traceparent and baggage).
+// The exporter is picked from OTEL_TRACES_EXPORTER:
+//
+//   - otlp: OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+//   - console (or stdout): readable spans on stdout, for local runs
+//   - none: spans are propagated but not exported
+//
+// When it's unset, otlp is used if OTEL_EXPORTER_OTLP_ENDPOINT is set, none otherwise.
+// service names the resource, overridden by OTEL_SERVICE_NAME.
+// The returned shutdown flushes the spans left and must be called before exiting.
+func Setup(ctx context.Context, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	res := resource.Default()
+	// the env wins over the code, as OTel's conventions go
+	if service != "" && os.Getenv("OTEL_SERVICE_NAME") == "" {
+		res, err = resource.Merge(res, resource.NewSchemaless(semconv.ServiceName(service)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if name == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		name = "otlp"
+	}
+
+	switch name {
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	case "console", "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "", "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, use otlp, console or none", name)
+}
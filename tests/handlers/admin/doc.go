@@ -0,0 +1,4 @@
+// Package admin holds the back-office routes, all of them under /admin.
+//
+// mug:group /admin > CoolMiddleware
+package admin
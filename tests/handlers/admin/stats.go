@@ -0,0 +1,15 @@
+package admin
+
+import "net/http"
+
+type statsOutput struct {
+	Users int `json:"users"`
+}
+
+// Stats counts the registered users.
+//
+// mug:handler GET /stats
+// > FactLoggingMiddleware > Header("X-Brew", "espresso > latte") > brew.BodyLimit
+func Stats(input struct{}) (code int, body statsOutput) {
+	return http.StatusOK, statsOutput{Users: 42}
+}
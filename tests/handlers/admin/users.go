@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+type staffClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+	Scope string   `json:"scope"`
+}
+
+type banOutput struct {
+	BannedBy string `json:"banned_by"`
+}
+
+type banInput struct {
+	mug.BearerAuth[staffClaims]
+}
+
+// BanUser bans a user for good.
+//
+// mug:handler POST /users/{id}/ban
+// mug:require role=admin,moderator scope=users:write
+func BanUser(input banInput) (int, banOutput) {
+	return http.StatusOK, banOutput{BannedBy: input.Claims.Subject}
+}
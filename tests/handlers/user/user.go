@@ -10,7 +10,7 @@ import (
 
 // testing struct desserialization
 type CreateUserInput struct {
-	Username string `json:"username" validate:"required,min=6"`
+	Username string `json:"username" validate:"required,min=6" example:"batman"`
 }
 
 type returnType struct {
@@ -19,6 +19,11 @@ type returnType struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// CreateUser registers a new user.
+// Only batman gets the admin role.
+//
+// mug:tag users
+// mug:operationId createUser
 // mug:handler POST /user/register
 // > CoolMiddleware > FactLoggingMiddleware
 func CreateUser(input CreateUserInput) (code int, body returnType) {
@@ -50,6 +55,7 @@ type PublishAuth struct {
 	jwt.RegisteredClaims
 }
 
+// mug:response 202 Accepted
 // mug:handler POST /rabbit
 // > CoolMiddleware
 func PublishToRabbit(ctx PublishInput) (code int, body any) {
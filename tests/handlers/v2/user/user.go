@@ -0,0 +1,25 @@
+package user
+
+import (
+	"net/http"
+)
+
+type CreateUserInput struct {
+	Username string `json:"username" validate:"required,min=6" example:"batman"`
+	Email    string `json:"email" validate:"required,email" example:"bruce@wayne.com"`
+}
+
+type createdUser struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// CreateUser registers a new user, now with an email.
+// Mounted at /v2/user/register, next to the v1 handler.
+//
+// mug:tag users
+// mug:response 201 Created
+// mug:handler POST /user/register
+func CreateUser(input CreateUserInput) (code int, body createdUser) {
+	return http.StatusCreated, createdUser{Username: input.Username, Email: input.Email}
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/sh-lucas/mug/internal/apidiff"
+)
+
+const baseSpec = `
+openapi: 3.0.0
+info: {title: Mug API, version: 1.0.0}
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                username: {type: string}
+                role: {type: string, enum: [admin, user]}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id]
+                properties:
+                  id: {type: string}
+  /coffee:
+    get:
+      responses:
+        "200": {description: ok}
+`
+
+const currentSpec = `
+openapi: 3.0.0
+info: {title: Mug API, version: 1.0.0}
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [email]
+              properties:
+                username: {type: string}
+                email: {type: string}
+                role: {type: string, enum: [admin]}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id]
+                properties:
+                  id: {type: integer}
+                  name: {type: string}
+`
+
+func TestAPIDiff(t *testing.T) {
+	base, err := apidiff.Load([]byte(baseSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := apidiff.Load([]byte(currentSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := apidiff.Compare(base, current)
+	if !apidiff.HasBreaking(changes) {
+		t.Fatalf("expected breaking changes, got %v", changes)
+	}
+
+	expected := map[string]bool{
+		"path /coffee removed":                        true,
+		`request body field "email" added`:            true, // required
+		`request body field "role" no longer accepts`: true, // narrowed enum
+		`response 200 field "id" changed type`:        true,
+		`response 200 field "name" added`:             false,
+	}
+	for msg, breaking := range expected {
+		found := false
+		for _, change := range changes {
+			if strings.Contains(change.String(), msg) {
+				found = true
+				if change.Breaking != breaking {
+					t.Errorf("%q: expected breaking=%v", change, breaking)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing change %q in %v", msg, changes)
+		}
+	}
+}
+
+// bodySpec is a spec with one operation taking and returning these schemas.
+const bodySpec = `
+openapi: 3.0.0
+info: {title: Mug API, version: 1.0.0}
+components:
+  schemas:
+    Card: {type: object, properties: {number: {type: string}}}
+    Pix: {type: object, properties: {key: {type: string}}}
+paths:
+  /orders:
+    post:
+      requestBody:
+        required: %t
+        content:
+          application/json:
+            schema: %s
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema: %s
+`
+
+func TestAPIDiffBodies(t *testing.T) {
+	type body struct {
+		required          bool
+		request, response string
+	}
+	card, pix := `{$ref: "#/components/schemas/Card"}`, `{$ref: "#/components/schemas/Pix"}`
+	cases := []struct {
+		base, current body
+		change        string
+		breaking      bool
+	}{
+		{body{false, "{}", "{}"}, body{true, "{}", "{}"}, "request body is now required", true},
+		{body{true, "{}", "{}"}, body{false, "{}", "{}"}, "request body is now optional", false},
+		{body{false, "{}", "{}"}, body{false, "{type: string}", "{}"}, "request body now only accepts string", true},
+		{body{false, "{}", "{}"}, body{false, "{}", "{type: string}"}, "response 200 now only returns string", false},
+		{
+			body{false, "{oneOf: [" + card + ", " + pix + "]}", "{}"}, body{false, "{oneOf: [" + card + "]}", "{}"},
+			"request body no longer accepts oneOf Pix", true,
+		},
+		{
+			body{false, "{oneOf: [" + card + "]}", "{}"}, body{false, "{oneOf: [" + pix + ", " + card + "]}", "{}"},
+			"request body oneOf Pix added", false,
+		},
+		{
+			body{false, "{}", "{anyOf: [{type: string}]}"}, body{false, "{}", "{anyOf: [{type: string}, {type: integer}]}"},
+			"response 200 anyOf integer added (may now be returned)", true,
+		},
+		{
+			body{false, "{}", "{}"}, body{false, "{anyOf: [{type: string}]}", "{}"},
+			"request body anyOf string added (now required)", true,
+		},
+		{
+			body{false, "{allOf: [" + card + "]}", "{}"}, body{false, "{allOf: [" + card + ", " + pix + "]}", "{}"},
+			"request body allOf Pix added (now required)", true,
+		},
+		{
+			body{false, "{}", "{allOf: [" + card + ", " + pix + "]}"}, body{false, "{}", "{allOf: [" + card + "]}"},
+			"response 200 no longer returns allOf Pix", true,
+		},
+		{
+			body{false, "{oneOf: [" + card + "]}", "{}"}, body{false, "{oneOf: [{$ref: \"#/components/schemas/Card\", type: object}]}", "{}"},
+			"", false,
+		},
+	}
+	load := func(b body) *openapi3.T {
+		t.Helper()
+		spec, err := apidiff.Load([]byte(fmt.Sprintf(bodySpec, b.required, b.request, b.response)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return spec
+	}
+
+	for _, c := range cases {
+		changes := apidiff.Compare(load(c.base), load(c.current))
+		if c.change == "" {
+			if len(changes) != 0 {
+				t.Errorf("%+v -> %+v: unexpected changes %v", c.base, c.current, changes)
+			}
+			continue
+		}
+		if len(changes) != 1 || changes[0].Message != c.change || changes[0].Breaking != c.breaking {
+			t.Errorf("%+v -> %+v: got %v, want %q (breaking %v)", c.base, c.current, changes, c.change, c.breaking)
+		}
+	}
+}
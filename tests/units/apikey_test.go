@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+type KeyInput struct {
+	mug.APIKey
+}
+
+func WhoAmI(input KeyInput) (int, string) {
+	return http.StatusOK, input.Principal.Name
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	t.Setenv("MUG_API_KEYS", "billing:sha256:"+mug.HashAPIKey("b1ll")+", reports:r3p0rts")
+	h := spout.ConvertHandler(WhoAmI)
+
+	cases := []struct {
+		name, header, query string
+		code                int
+		body                string
+	}{
+		{name: "hashed key in the header", header: "b1ll", code: 200, body: "\"billing\"\n"},
+		{name: "plain key in the query", query: "r3p0rts", code: 200, body: "\"reports\"\n"},
+		{name: "unknown key", header: "nope", code: 401},
+		{name: "missing key", code: 401},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/whoami?api_key="+c.query, nil)
+		if c.header != "" {
+			r.Header.Set("X-API-Key", c.header)
+		}
+		w := serve(h, r)
+		if w.Code != c.code || c.body != "" && w.Body.String() != c.body {
+			t.Errorf("%s: got %d %q", c.name, w.Code, w.Body)
+		}
+	}
+}
+
+type ServiceAccount struct {
+	ID string
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	mug.SetKeyStore(mug.KeyStoreFunc[ServiceAccount](func(_ context.Context, key string) (ServiceAccount, error) {
+		if key != "svc-key" {
+			return ServiceAccount{}, mug.ErrUnknownKey
+		}
+		return ServiceAccount{ID: "svc-1"}, nil
+	}))
+
+	var got ServiceAccount
+	h := spout.ConvertHandler(func(input struct{ mug.APIKeyAuth[ServiceAccount] }) (int, string) {
+		got = input.Principal
+		return http.StatusOK, "ok"
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-API-Key", "svc-key")
+	if w := serve(h, r); w.Code != 200 || got.ID != "svc-1" {
+		t.Errorf("principal not filled from the store: %d %+v", w.Code, got)
+	}
+}
+
+func TestAPIKeySecurity(t *testing.T) {
+	spout.MakeHandler(http.NewServeMux(), "GET /whoami", WhoAmI)
+
+	spec := loadSpec(t)
+	scheme := spec.Components.SecuritySchemes["apiKeyHeader"]
+	if scheme == nil || scheme.Value.Type != "apiKey" || scheme.Value.In != "header" || scheme.Value.Name != "X-API-Key" {
+		t.Fatalf("apiKeyHeader scheme not declared: %+v", spec.Components.SecuritySchemes)
+	}
+	op := spec.Paths.Find("/whoami").Get
+	if op.Security == nil || len(*op.Security) != 2 {
+		t.Errorf("header and query should be alternatives: %+v", op.Security)
+	}
+	if op.Responses.Status(401) == nil {
+		t.Errorf("401 not documented")
+	}
+}
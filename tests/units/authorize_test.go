@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+type StaffClaims struct {
+	jwt.RegisteredClaims
+	Roles  []string `json:"roles"`
+	Scope  string   `json:"scope"`
+	Tenant string   `json:"tenant"`
+}
+
+// Authorize keeps the staff of other tenants out of /tenants/acme.
+func (c *StaffClaims) Authorize(r *http.Request) error {
+	if tenant := r.PathValue("tenant"); tenant != "" && tenant != c.Tenant {
+		return errors.New("Not a member of " + tenant + ".")
+	}
+	return nil
+}
+
+type StaffInput struct {
+	mug.BearerAuth[StaffClaims]
+}
+
+func Refund(input StaffInput) (int, OrderOutput) {
+	return http.StatusOK, OrderOutput{ID: "1"}
+}
+
+func TestAuthorize(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	mug.ConfigureJWT(mug.JWTConfig{Secret: "s3cr3t"})
+
+	mux := http.NewServeMux()
+	spout.MakeRoute(mux, "POST /tenants/{tenant}/refunds", spout.Meta{
+		Require: spout.Requirement{Roles: []string{"admin", "support"}, Scopes: []string{"orders:write"}},
+	}, Refund)
+
+	cases := []struct {
+		name  string
+		roles []string
+		scope string
+		code  int
+		error string
+	}{
+		{name: "support with the scope", roles: []string{"support"}, scope: "orders:read orders:write", code: 200},
+		{name: "no role", roles: []string{"viewer"}, scope: "orders:write", code: 403, error: "Missing role admin or support."},
+		{name: "no scope", roles: []string{"admin"}, scope: "orders:read", code: 403, error: "Missing scope orders:write."},
+	}
+	for _, c := range cases {
+		claims := StaffClaims{Roles: c.roles, Scope: c.scope, Tenant: "acme"}
+		r := httptest.NewRequest("POST", "/tenants/acme/refunds", nil)
+		r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", claims, []byte("s3cr3t")))
+		w := serve(mux, r)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.error) {
+			t.Errorf("%s: got %d %s", c.name, w.Code, w.Body)
+		}
+	}
+
+	// the claims' own Authorize
+	claims := StaffClaims{Roles: []string{"admin"}, Scope: "orders:write", Tenant: "globex"}
+	r := httptest.NewRequest("POST", "/tenants/acme/refunds", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", claims, []byte("s3cr3t")))
+	if w := serve(mux, r); w.Code != 403 || !strings.Contains(w.Body.String(), "Not a member of acme.") {
+		t.Errorf("Authorize not called: %d %s", w.Code, w.Body)
+	}
+
+	op := loadSpec(t).Paths.Find("/tenants/{tenant}/refunds").Post
+	if forbidden := op.Responses.Status(403); forbidden == nil || !strings.Contains(*forbidden.Value.Description, "role admin or support") {
+		t.Errorf("requirement not documented in the 403 response")
+	}
+	if _, ok := op.Extensions["x-require"]; !ok {
+		t.Errorf("x-require extension missing: %v", op.Extensions)
+	}
+}
+
+type ReportInput struct {
+	mug.Auth
+}
+
+func Report(input ReportInput) (int, OrderOutput) {
+	return http.StatusOK, OrderOutput{ID: input.Claims.Subject}
+}
+
+func TestAuthorizeRegisteredClaims(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	mug.ConfigureJWT(mug.JWTConfig{Secret: "s3cr3t"})
+
+	mux := http.NewServeMux()
+	spout.MakeRoute(mux, "GET /reports", spout.Meta{
+		Require: spout.Requirement{Roles: []string{"admin"}, Scopes: []string{"reports:read"}},
+	}, Report)
+
+	// jwt.RegisteredClaims has no roles or scopes: they're read from the token
+	cases := []struct {
+		claims jwt.MapClaims
+		code   int
+	}{
+		{jwt.MapClaims{"sub": "ana", "roles": []string{"admin"}, "scope": "reports:read"}, 200},
+		{jwt.MapClaims{"sub": "ana", "roles": "viewer admin", "scp": []string{"reports:read"}}, 200},
+		{jwt.MapClaims{"sub": "ana", "roles": []string{"viewer"}, "scope": "reports:read"}, 403},
+		{jwt.MapClaims{"sub": "ana"}, 403},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/reports", nil)
+		r.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, "", c.claims, []byte("s3cr3t")))
+		if w := serve(mux, r); w.Code != c.code {
+			t.Errorf("%v: got %d %s", c.claims, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRequireWithoutAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a requirement on an input without auth mixin was registered")
+		}
+	}()
+	spout.MakeRoute(http.NewServeMux(), "GET /unguarded", spout.Meta{
+		Require: spout.Requirement{Roles: []string{"admin"}},
+	}, LegacyOrders)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg/brew"
+)
+
+var hello = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "` + strings.Repeat("hello ", 300) + `"}`))
+})
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBrewRequestID(t *testing.T) {
+	var seen string
+	h := brew.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = brew.RequestIDFrom(r.Context())
+	}))
+
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	if seen == "" || w.Header().Get("X-Request-ID") != seen {
+		t.Errorf("request ID not generated: %q, answered %q", seen, w.Header().Get("X-Request-ID"))
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "from-the-proxy")
+	serve(h, r)
+	if seen != "from-the-proxy" {
+		t.Errorf("incoming request ID not kept, got %q", seen)
+	}
+}
+
+func TestBrewCORS(t *testing.T) {
+	h := brew.CORS(hello)
+
+	r := httptest.NewRequest("OPTIONS", "/orders", nil)
+	r.Header.Set("Origin", "https://shop.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := serve(h, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("preflight not answered: %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "POST") {
+		t.Errorf("POST not allowed: %v", w.Header())
+	}
+}
+
+func TestBrewCompress(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := serve(brew.Compress(hello), r)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("response not compressed: %v", w.Header())
+	}
+	body, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := io.ReadAll(body); !strings.HasPrefix(string(out), `{"message": "hello`) {
+		t.Errorf("unexpected body %q", out)
+	}
+
+	// small responses are not worth it
+	small := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("{}")) })
+	if w := serve(brew.Compress(small), r); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "{}" {
+		t.Errorf("small response compressed: %v %q", w.Header(), w.Body)
+	}
+}
+
+func TestBrewBodyLimit(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 2<<20)))
+	if w := serve(brew.BodyLimit(hello), r); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("big body accepted: %d", w.Code)
+	}
+}
+
+func TestBrewRealIP(t *testing.T) {
+	var ip string
+	h := brew.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ip = r.RemoteAddr }))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:4321"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	serve(h, r)
+	if ip != "203.0.113.7" {
+		t.Errorf("client IP not read from a trusted proxy, got %q", ip)
+	}
+
+	r.RemoteAddr = "198.51.100.1:4321" // not a proxy, lying
+	serve(h, r)
+	if ip != "198.51.100.1:4321" {
+		t.Errorf("headers believed from an untrusted peer, got %q", ip)
+	}
+}
+
+func TestBrewSecureHeaders(t *testing.T) {
+	w := serve(brew.SecureHeaders(hello), httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("security headers not set: %v", w.Header())
+	}
+	if w.Header().Get("Content-Security-Policy") != "" {
+		t.Errorf("empty headers must not be sent")
+	}
+}
+
+func TestBrewTimeoutAndRecover(t *testing.T) {
+	defer brew.Configure(brew.Defaults)
+	settings := brew.Defaults
+	settings.Timeout = 10 * time.Millisecond
+	settings.Recover.Stack = false
+	brew.Configure(settings)
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	w := serve(brew.Timeout(slow), httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("timeout not answered as json 503: %d %v", w.Code, w.Header())
+	}
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	if w := serve(brew.Recover(panicking), httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusInternalServerError {
+		t.Errorf("panic not recovered: %d", w.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+type ProfileForm struct {
+	Name   string   `form:"name" validate:"required"`
+	Age    int      `form:"age"`
+	Tags   []string `form:"tag"`
+	Public bool     `form:"public"`
+}
+
+type ProfileInput struct {
+	mug.Form[ProfileForm]
+}
+
+func UpdateProfile(input ProfileInput) (int, ProfileForm) {
+	return http.StatusOK, input.Body
+}
+
+type AvatarForm struct {
+	Caption string                  `form:"caption"`
+	Avatar  *multipart.FileHeader   `form:"avatar" accept:"image/png" maxsize:"1KB" validate:"required"`
+	Extras  []*multipart.FileHeader `form:"extra"`
+}
+
+type AvatarInput struct {
+	mug.Multipart[AvatarForm]
+}
+
+type AvatarOutput struct {
+	Caption string `json:"caption"`
+	Type    string `json:"type"`
+	Read    int    `json:"read"`
+	Extras  int    `json:"extras"`
+}
+
+func UploadAvatar(input AvatarInput) (int, AvatarOutput) {
+	file, err := input.Body.Avatar.Open()
+	if err != nil {
+		return http.StatusInternalServerError, AvatarOutput{}
+	}
+	defer file.Close()
+	content, _ := io.ReadAll(file)
+	return http.StatusOK, AvatarOutput{
+		Caption: input.Body.Caption,
+		Type:    input.Body.Avatar.Header.Get("Content-Type"),
+		Read:    len(content),
+		Extras:  len(input.Body.Extras),
+	}
+}
+
+// png is the start of a PNG image, enough to be sniffed as one.
+func png(size int) []byte {
+	return append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, size-8)...)
+}
+
+// upload posts files, by form name, to /avatar.
+func upload(mux http.Handler, files map[string][]byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	form.WriteField("caption", "me")
+	for name, content := range files {
+		part, _ := form.CreateFormFile(name, name+".bin")
+		part.Write(content)
+	}
+	form.Close()
+	r := httptest.NewRequest("POST", "/avatar", body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return serve(mux, r)
+}
+
+func TestForm(t *testing.T) {
+	mux := http.NewServeMux()
+	spout.MakeRoute(mux, "POST /profile", spout.Meta{}, UpdateProfile)
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/profile", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return serve(mux, r)
+	}
+	form := "application/x-www-form-urlencoded"
+
+	values := url.Values{"name": {"ana"}, "age": {"30"}, "tag": {"a", "b"}, "public": {"on"}}
+	if w := post(form, values.Encode()); w.Code != 200 || w.Body.String() != `{"Name":"ana","Age":30,"Tags":["a","b"],"Public":true}`+"\n" {
+		t.Errorf("form not bound: %d %s", w.Code, w.Body)
+	}
+	if w := post(form, "name=ana&age=thirty"); w.Code != 400 || !strings.Contains(w.Body.String(), `"age":"age must be an integer"`) {
+		t.Errorf("invalid number: %d %s", w.Code, w.Body)
+	}
+	if w := post(form, "age=30"); w.Code != 400 || !strings.Contains(w.Body.String(), `"name"`) {
+		t.Errorf("missing required field: %d %s", w.Code, w.Body)
+	}
+	if w := post("application/json", `{"name":"ana"}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json accepted as a form: %d", w.Code)
+	}
+
+	op := loadSpec(t).Paths.Find("/profile").Post
+	if op.RequestBody.Value.Content.Get(form) == nil {
+		t.Errorf("form body not documented as %s", form)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	defer func(size, memory int64) {
+		mug.MaxMultipartSize, mug.MultipartMemory = size, memory
+	}(mug.MaxMultipartSize, mug.MultipartMemory)
+	// the files go to temp files
+	mug.MultipartMemory = 16
+
+	mux := http.NewServeMux()
+	spout.MakeRoute(mux, "POST /avatar", spout.Meta{}, UploadAvatar)
+
+	w := upload(mux, map[string][]byte{"avatar": png(600), "extra": []byte("notes")})
+	if w.Code != 200 || w.Body.String() != `{"caption":"me","type":"image/png","read":600,"extras":1}`+"\n" {
+		t.Errorf("upload not bound: %d %s", w.Code, w.Body)
+	}
+	if w := upload(mux, map[string][]byte{"avatar": []byte("%PDF-1.7 not an image")}); w.Code != 400 || !strings.Contains(w.Body.String(), "avatar must be image/png, not application/pdf") {
+		t.Errorf("file type not sniffed: %d %s", w.Code, w.Body)
+	}
+	if w := upload(mux, map[string][]byte{"avatar": png(2048)}); w.Code != 400 || !strings.Contains(w.Body.String(), "larger than 1KB") {
+		t.Errorf("file size not limited: %d %s", w.Code, w.Body)
+	}
+	if w := upload(mux, map[string][]byte{"extra": []byte("notes")}); w.Code != 400 || !strings.Contains(w.Body.String(), `"avatar"`) {
+		t.Errorf("missing required file: %d %s", w.Code, w.Body)
+	}
+	mug.MaxMultipartSize = 512
+	if w := upload(mux, map[string][]byte{"avatar": png(600)}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body size not limited: %d %s", w.Code, w.Body)
+	}
+
+	media := loadSpec(t).Paths.Find("/avatar").Post.RequestBody.Value.Content.Get("multipart/form-data")
+	if media == nil {
+		t.Fatal("upload not documented as multipart/form-data")
+	}
+	avatar := media.Schema.Value.Properties["avatar"]
+	if avatar == nil || avatar.Value.Format != "binary" || media.Encoding["avatar"].ContentType != "image/png" {
+		t.Errorf("avatar not documented as a png file: %+v", avatar)
+	}
+	if len(media.Schema.Value.Required) != 1 || media.Schema.Value.Required[0] != "avatar" {
+		t.Errorf("required files: %v", media.Schema.Value.Required)
+	}
+}
+
+type BrokenForm struct {
+	Roast chan string `form:"roast"`
+}
+
+type BrokenInput struct {
+	mug.Form[BrokenForm]
+}
+
+func TestFormCheckedAtRegistration(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a form field that can't be bound was registered")
+		}
+	}()
+	spout.MakeHandler(http.NewServeMux(), "POST /broken", func(BrokenInput) (int, string) { return 200, "" })
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sh-lucas/mug/pkg/health"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+func TestHealth(t *testing.T) {
+	health.Register(health.Check{Name: "process", Liveness: true, Func: func(context.Context) error { return nil }})
+	health.Register(health.Check{Name: "db", Func: func(context.Context) error { return errors.New("connection refused") }})
+	health.Register(health.Check{Name: "cache", Timeout: 10 * time.Millisecond, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	defer func() {
+		for _, name := range []string{"process", "db", "cache"} {
+			health.Unregister(name)
+		}
+		health.MarkServing()
+	}()
+
+	mux := http.NewServeMux()
+	spout.ServeHealth(mux)
+	report := func(path string) (int, health.Report) {
+		w := serve(mux, httptest.NewRequest("GET", path, nil))
+		var report health.Report
+		if err := jsoniter.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s answered %q: %v", path, w.Body, err)
+		}
+		return w.Code, report
+	}
+
+	// readiness checks don't restart the app
+	if code, live := report("/healthz"); code != 200 || len(live.Checks) != 1 || live.Checks["process"].Status != health.StatusOK {
+		t.Errorf("/healthz = %d %+v", code, live)
+	}
+
+	code, ready := report("/readyz")
+	if code != http.StatusServiceUnavailable || ready.Status != health.StatusFailing {
+		t.Errorf("/readyz should fail, got %d %+v", code, ready)
+	}
+	if db := ready.Checks["db"]; db.Status != health.StatusFailing || db.Error != "connection refused" {
+		t.Errorf("db check = %+v", db)
+	}
+	if cache := ready.Checks["cache"]; cache.Status != health.StatusTimeout || cache.LatencyMS < 10 {
+		t.Errorf("cache check = %+v", cache)
+	}
+	if _, ok := ready.Checks["rabbit"]; !ok {
+		t.Errorf("rabbit should register its own check")
+	}
+
+	// like spout.Serve on SIGTERM
+	health.MarkShuttingDown()
+	if code, ready := report("/readyz"); code != http.StatusServiceUnavailable || ready.Status != health.StatusShuttingDown {
+		t.Errorf("/readyz while shutting down = %d %+v", code, ready)
+	}
+	if code, _ := report("/healthz"); code != 200 {
+		t.Errorf("liveness must not fail while shutting down, got %d", code)
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+type ClaimsInput struct {
+	mug.Auth
+}
+
+func Me(input ClaimsInput) (int, string) {
+	return http.StatusOK, input.Claims.Subject
+}
+
+// bearer calls Me with token.
+func bearer(token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return serve(spout.ConvertHandler(Me), r)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.Claims, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTPublicKey(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	file := filepath.Join(t.TempDir(), "public.pem")
+	os.WriteFile(file, publicPEM, 0o600)
+
+	err := mug.ConfigureJWT(mug.JWTConfig{PublicKeyFile: file, Issuer: "https://id.example.com", Audience: "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.RegisteredClaims{Subject: "ana", Issuer: "https://id.example.com", Audience: jwt.ClaimStrings{"orders"}}
+	if w := bearer(sign(t, jwt.SigningMethodRS256, "", claims, key)); w.Code != 200 || w.Body.String() != "\"ana\"\n" {
+		t.Errorf("RS256 token rejected: %d %s", w.Code, w.Body)
+	}
+
+	claims.Issuer = "https://evil.example.com"
+	if w := bearer(sign(t, jwt.SigningMethodRS256, "", claims, key)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of another issuer accepted: %d", w.Code)
+	}
+
+	// the public key is public: it must not sign HMAC tokens
+	claims.Issuer = "https://id.example.com"
+	if w := bearer(sign(t, jwt.SigningMethodHS256, "", claims, publicPEM)); w.Code != http.StatusUnauthorized {
+		t.Errorf("HS256 token signed with the public key accepted: %d", w.Code)
+	}
+	if !jsoniter.Valid(bearer("not.a.token").Body.Bytes()) {
+		t.Errorf("error payload is not json")
+	}
+}
+
+func TestJWTJWKS(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	keys := map[string]*ecdsa.PrivateKey{}
+	var current atomic.Value // kid served by the provider
+	var fetches atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		kid := current.Load().(string)
+		serveJWKS(w, kid, keys[kid])
+	}))
+	defer provider.Close()
+
+	for _, kid := range []string{"k1", "k2"} {
+		keys[kid], _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	current.Store("k1")
+	if err := mug.ConfigureJWT(mug.JWTConfig{JWKSURL: provider.URL, JWKSRefresh: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.RegisteredClaims{Subject: "bob"}
+	if w := bearer(sign(t, jwt.SigningMethodES256, "k1", claims, keys["k1"])); w.Code != 200 {
+		t.Errorf("ES256 token rejected: %d %s", w.Code, w.Body)
+	}
+
+	// the provider rotates its key
+	current.Store("k2")
+	time.Sleep(2 * time.Millisecond)
+	if w := bearer(sign(t, jwt.SigningMethodES256, "k2", claims, keys["k2"])); w.Code != 200 {
+		t.Errorf("token of the rotated key rejected: %d %s", w.Code, w.Body)
+	}
+	if w := bearer(sign(t, jwt.SigningMethodES256, "k1", claims, keys["k1"])); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of the retired key accepted: %d", w.Code)
+	}
+	if fetches.Load() < 2 {
+		t.Errorf("JWKS not fetched again on rotation")
+	}
+}
+
+func TestJWTJWKSConcurrent(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var fetches atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(100 * time.Millisecond) // a slow provider
+		serveJWKS(w, "k1", key)
+	}))
+	defer provider.Close()
+	if err := mug.ConfigureJWT(mug.JWTConfig{JWKSURL: provider.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the requests arriving during the fetch wait for it instead of fetching again
+	token := sign(t, jwt.SigningMethodES256, "k1", jwt.RegisteredClaims{Subject: "dora"}, key)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := bearer(token); w.Code != 200 {
+				t.Errorf("token rejected: %d %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times", n)
+	}
+}
+
+// serveJWKS answers a set with the public part of key.
+func serveJWKS(w http.ResponseWriter, kid string, key *ecdsa.PrivateKey) {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.PublicKey.X.FillBytes(x)
+	key.PublicKey.Y.FillBytes(y)
+	jsoniter.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "EC", "crv": "P-256", "kid": kid, "use": "sig",
+		"x": base64.RawURLEncoding.EncodeToString(x),
+		"y": base64.RawURLEncoding.EncodeToString(y),
+	}}})
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	if err := mug.ConfigureJWT(mug.JWTConfig{Algorithms: []string{"none"}}); err == nil {
+		t.Errorf("the none algorithm must be refused")
+	}
+	mug.ConfigureJWT(mug.JWTConfig{Secret: "s3cr3t", Algorithms: []string{"HS512"}})
+
+	claims := jwt.RegisteredClaims{Subject: "carla"}
+	if w := bearer(sign(t, jwt.SigningMethodHS512, "", claims, []byte("s3cr3t"))); w.Code != 200 {
+		t.Errorf("HS512 token rejected: %d %s", w.Code, w.Body)
+	}
+	if w := bearer(sign(t, jwt.SigningMethodHS256, "", claims, []byte("s3cr3t"))); w.Code != http.StatusUnauthorized {
+		t.Errorf("HS256 token accepted while only HS512 is allowed: %d", w.Code)
+	}
+}
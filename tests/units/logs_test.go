@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sh-lucas/mug/pkg"
+	"github.com/sh-lucas/mug/pkg/brew"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+func TestRequestLog(t *testing.T) {
+	var out bytes.Buffer
+	spout.SetLogger(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer spout.SetLogger(slog.Default())
+
+	mux := http.NewServeMux()
+	spout.MakeHandler(mux, "GET /logged/{id}", LegacyOrders)
+	serve(brew.RequestID(mux), httptest.NewRequest("GET", "/logged/7", nil))
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("not a json line: %q", out.String())
+	}
+	if line["pattern"] != "GET /logged/{id}" || line["status"] != 200.0 || line["request_id"] == "" || line["latency"] == nil {
+		t.Errorf("request log misses fields: %v", line)
+	}
+	if line["level"] != "INFO" {
+		t.Errorf("request logged at %v", line["level"])
+	}
+}
+
+func TestBasicRequestLog(t *testing.T) {
+	var out bytes.Buffer
+	spout.SetLogger(slog.New(slog.NewJSONHandler(&out, nil)))
+	defer spout.SetLogger(slog.Default())
+
+	mux := http.NewServeMux()
+	spout.Handle(mux, "/missing", http.NotFoundHandler())
+	spout.Handle(mux, "GET /broken", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("espresso machine") }))
+
+	serve(mux, httptest.NewRequest("POST", "/missing", nil))
+	if res := serve(mux, httptest.NewRequest("GET", "/broken", nil)); res.Code != 500 {
+		t.Errorf("panic answered %d", res.Code)
+	}
+
+	var levels []string
+	for _, raw := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var line map[string]any
+		if err := json.Unmarshal(raw, &line); err != nil {
+			t.Fatalf("not a json line: %q", raw)
+		}
+		if line["msg"] == "request" {
+			levels = append(levels, line["level"].(string))
+		}
+	}
+	if strings.Join(levels, " ") != "WARN ERROR" {
+		t.Errorf("request levels = %v, want a warn for the 404 and an error for the panic", levels)
+	}
+
+	// brew.AccessLog writes the line instead
+	out.Reset()
+	serve(brew.AccessLog(mux), httptest.NewRequest("POST", "/missing", nil))
+	if strings.Contains(out.String(), `"msg":"request"`) {
+		t.Errorf("request logged twice: %s", out.String())
+	}
+}
+
+func TestPrettyHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(pkg.NewPrettyHandler(&out, slog.LevelInfo)).With("app", "coffee")
+	logger.Debug("hidden")
+	logger.WithGroup("req").Warn("slow request", "path", "/orders", "user", "bruce wayne")
+
+	got := out.String()
+	if strings.Contains(got, "hidden") {
+		t.Errorf("debug line written at info level: %q", got)
+	}
+	for _, want := range []string{"WARN", "slow request", "app", "req.path", `"bruce wayne"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sh-lucas/mug/pkg/metrics"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	jobs := metrics.NewCounter("test_jobs_total", "Jobs done.", "kind")
+	jobs.Inc(`say "hi"`)
+	jobs.Add(2, `say "hi"`)
+	sizes := metrics.NewHistogram("test_sizes", "Sizes.", []float64{1, 10})
+	sizes.Observe(0.5)
+	sizes.Observe(10)
+	sizes.Observe(50)
+
+	var out bytes.Buffer
+	metrics.Write(&out)
+	for _, want := range []string{
+		"# TYPE test_jobs_total counter",
+		`test_jobs_total{kind="say \"hi\""} 3`,
+		"# TYPE test_sizes histogram",
+		`test_sizes_bucket{le="1"} 1`,
+		`test_sizes_bucket{le="10"} 2`,
+		`test_sizes_bucket{le="+Inf"} 3`,
+		"test_sizes_sum 60.5",
+		"test_sizes_count 3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRouteMetrics(t *testing.T) {
+	defer func() { metrics.Enabled = false }()
+	mux := http.NewServeMux()
+	spout.ServeMetrics(mux)
+	spout.MakeHandler(mux, "GET /metered/{id}", LegacyOrders)
+
+	serve(mux, httptest.NewRequest("GET", "/metered/1", nil))
+	serve(mux, httptest.NewRequest("GET", "/metered/2", nil))
+
+	body := serve(mux, httptest.NewRequest("GET", "/metrics", nil)).Body.String()
+	for _, want := range []string{
+		`http_requests_total{route="GET /metered/{id}",code="200"} 2`,
+		`http_request_duration_seconds_count{route="GET /metered/{id}"} 2`,
+		`http_requests_in_flight{route="GET /metered/{id}"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
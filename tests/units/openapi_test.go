@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+	"github.com/sh-lucas/mug/tests/handlers/user"
+	v2user "github.com/sh-lucas/mug/tests/handlers/v2/user"
+)
+
+type OrderInput struct {
+	mug.Auth
+	mug.JsonBody[struct {
+		Item string `json:"item" validate:"required"`
+	}]
+}
+
+type OrderOutput struct {
+	ID string `json:"id"`
+}
+
+func CreateOrder(input OrderInput) (int, OrderOutput) {
+	return http.StatusCreated, OrderOutput{ID: "1"}
+}
+
+// loadSpec exports the registered routes and parses them back.
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := spout.WriteOpenAPI(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := openapi3.NewLoader().LoadFromData(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	spout.MakeRoute(http.NewServeMux(), "POST /orders", spout.Meta{
+		Summary:   "Creates an order",
+		Tags:      []string{"orders"},
+		Responses: []spout.Response{{Code: 201, Description: "Created"}},
+	}, CreateOrder)
+
+	op := loadSpec(t).Paths.Find("/orders").Post
+	if op.Summary != "Creates an order" || len(op.Tags) != 1 {
+		t.Errorf("meta not documented: %+v", op)
+	}
+	for _, code := range []int{201, 400, 401, 403} {
+		if op.Responses.Status(code) == nil {
+			t.Errorf("missing response %d", code)
+		}
+	}
+	if op.Responses.Status(200) != nil {
+		t.Errorf("default 200 documented despite the declared 201")
+	}
+}
+
+func TestOpenAPISecurity(t *testing.T) {
+	spout.MakeHandler(http.NewServeMux(), "POST /secure-orders", CreateOrder)
+
+	spec := loadSpec(t)
+	scheme := spec.Components.SecuritySchemes["bearerAuth"]
+	if scheme == nil || scheme.Value.Type != "http" || scheme.Value.Scheme != "bearer" {
+		t.Fatalf("bearerAuth scheme not declared: %+v", spec.Components.SecuritySchemes)
+	}
+
+	op := spec.Paths.Find("/secure-orders").Post
+	if op.Security == nil || len(*op.Security) != 1 {
+		t.Fatalf("operation not secured: %+v", op.Security)
+	}
+	if _, ok := (*op.Security)[0]["bearerAuth"]; !ok {
+		t.Errorf("operation doesn't require bearerAuth: %+v", op.Security)
+	}
+}
+
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+func ListOrders(input struct{}) (int, Page[OrderOutput]) {
+	return http.StatusOK, Page[OrderOutput]{}
+}
+
+func TestOpenAPIComponents(t *testing.T) {
+	spout.MakeHandler(http.NewServeMux(), "GET /orders", ListOrders)
+
+	spec := loadSpec(t)
+	for _, name := range []string{"Page_OrderOutput", "OrderOutput", "ErrorBody"} {
+		if spec.Components.Schemas[name] == nil {
+			t.Errorf("missing component %s in %v", name, spec.Components.Schemas)
+		}
+	}
+
+	schema := spec.Paths.Find("/orders").Get.Responses.Status(200).Value.Content.Get("application/json").Schema
+	if schema.Ref != "#/components/schemas/Page_OrderOutput" {
+		t.Errorf("response not referenced: %q", schema.Ref)
+	}
+}
+
+func TestOpenAPIClashingNames(t *testing.T) {
+	defer spout.Reset()
+	// the same names whichever route comes first
+	for _, v1First := range []bool{true, false} {
+		spout.Reset()
+		if v1First {
+			spout.MakeHandler(http.NewServeMux(), "POST /v1/users", user.CreateUser)
+		}
+		spout.MakeHandler(http.NewServeMux(), "POST /v2/users", v2user.CreateUser)
+		if !v1First {
+			spout.MakeHandler(http.NewServeMux(), "POST /v1/users", user.CreateUser)
+		}
+
+		schemas := loadSpec(t).Components.Schemas
+		for _, name := range []string{"handlers.user.CreateUserInput", "v2.user.CreateUserInput"} {
+			if schemas[name] == nil {
+				t.Errorf("v1 first %v: missing component %s", v1First, name)
+			}
+		}
+		if schemas["CreateUserInput"] != nil {
+			t.Errorf("v1 first %v: a clashing type kept the bare name", v1First)
+		}
+	}
+}
+
+func Teapot(input struct{}) (int, OrderOutput) {
+	return http.StatusTeapot, OrderOutput{ID: "1"}
+}
+
+func TestContractDrift(t *testing.T) {
+	t.Setenv("MUG_ENV", "dev")
+	mux := http.NewServeMux()
+	spout.MakeHandler(mux, "GET /teapot", Teapot)
+
+	// the drift is only logged
+	var out bytes.Buffer
+	spout.SetLogger(slog.New(slog.NewTextHandler(&out, nil)))
+	defer spout.SetLogger(slog.Default())
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/teapot", nil))
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("the response must go through untouched, got %d", w.Code)
+	}
+	if !strings.Contains(out.String(), "doesn't match the spec") {
+		t.Errorf("undeclared 418 not reported, got %q", out)
+	}
+}
+
+type Receipt struct {
+	ID    string  `json:"id" example:"r-42"`
+	Total float64 `json:"total" example:"9.90"`
+}
+
+func (Receipt) Example() any {
+	return Receipt{ID: "r-1", Total: 3.5}
+}
+
+func Checkout(input struct{}) (int, Receipt) {
+	return http.StatusOK, Receipt{}
+}
+
+func TestOpenAPIExamples(t *testing.T) {
+	spout.MakeRoute(http.NewServeMux(), "POST /checkout", spout.Meta{
+		ResponseExamples: map[int]string{409: `{"error":"conflict"}`},
+		Responses:        []spout.Response{{Code: 409, Description: "Already paid"}},
+	}, Checkout)
+
+	spec := loadSpec(t)
+	receipt := spec.Components.Schemas["Receipt"].Value
+	if receipt.Properties["id"].Value.Example != "r-42" || receipt.Properties["total"].Value.Example != 9.9 {
+		t.Errorf("tag examples not applied: %v, %v", receipt.Properties["id"].Value.Example, receipt.Properties["total"].Value.Example)
+	}
+
+	op := spec.Paths.Find("/checkout").Post
+	ok := op.Responses.Status(200).Value.Content.Get("application/json").Example
+	if ok.(map[string]any)["id"] != "r-1" {
+		t.Errorf("Example() not used for the 200 response: %v", ok)
+	}
+	conflict := op.Responses.Status(409).Value.Content.Get("application/json").Example
+	if conflict.(map[string]any)["error"] != "conflict" {
+		t.Errorf("raw example not used for the 409 response: %v", conflict)
+	}
+}
+
+func LegacyOrders(input struct{}) (int, OrderOutput) {
+	return http.StatusOK, OrderOutput{ID: "1"}
+}
+
+func TestVersions(t *testing.T) {
+	spout.Versions["v0"] = spout.APIVersion{
+		Deprecated:   true,
+		DeprecatedAt: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	mux := http.NewServeMux()
+	spout.MakeRoute(mux, "GET /v0/orders", spout.Meta{Version: "v0"}, LegacyOrders)
+	spout.ServeDocs(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v0/orders", nil))
+	if got := w.Header().Get("Deprecation"); got != "@1738281600" {
+		t.Errorf("Deprecation header = %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Wed, 31 Dec 2025 00:00:00 GMT" {
+		t.Errorf("Sunset header = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/v0/swagger.json", nil))
+	spec, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Paths.Len() != 1 || spec.Paths.Find("/v0/orders") == nil {
+		t.Errorf("the v0 spec should only have its own route, got %v", spec.Paths.InMatchingOrder())
+	}
+	if !spec.Paths.Find("/v0/orders").Get.Deprecated {
+		t.Errorf("routes of a deprecated version must be deprecated")
+	}
+}
+
+func TestReset(t *testing.T) {
+	// like the generated Register, called for a second mux
+	for range 2 {
+		spout.Reset()
+		spout.MakeHandler(http.NewServeMux(), "GET /reset/orders", LegacyOrders)
+	}
+
+	spec := loadSpec(t)
+	if spec.Paths.Len() != 1 || spec.Paths.Find("/reset/orders") == nil {
+		t.Errorf("only the last registration should be documented, got %v", spec.Paths.InMatchingOrder())
+	}
+}
+
+func TestDocsAssets(t *testing.T) {
+	defer func(docs spout.DocsConfig) { spout.Docs = docs }(spout.Docs)
+	spout.Docs.AssetsDir = t.TempDir()
+	os.WriteFile(filepath.Join(spout.Docs.AssetsDir, "scalar.js"), []byte("// scalar"), 0o600)
+	mux := http.NewServeMux()
+	spout.ServeDocs(mux)
+
+	if w := serve(mux, httptest.NewRequest("GET", "/docs/assets/scalar.js", nil)); w.Code != 200 || w.Body.String() != "// scalar" {
+		t.Errorf("scalar.js of the assets folder not served: %d", w.Code)
+	}
+	if w := serve(mux, httptest.NewRequest("GET", "/docs/assets/swagger-ui-bundle.js", nil)); w.Code != 200 {
+		t.Errorf("embedded Swagger UI not served: %d", w.Code)
+	}
+}
+
+// TestDocsOffline checks that every renderer's page only loads assets served by the app.
+func TestDocsOffline(t *testing.T) {
+	defer func(docs spout.DocsConfig) { spout.Docs = docs }(spout.Docs)
+	scripts := regexp.MustCompile(`(?:src|href)="([^"]+)"`)
+	for _, renderer := range []string{"swagger", "redoc", "scalar"} {
+		spout.Docs.Renderer = renderer
+		mux := http.NewServeMux()
+		spout.ServeDocs(mux)
+
+		page := serve(mux, httptest.NewRequest("GET", "/docs", nil)).Body.String()
+		for _, asset := range scripts.FindAllStringSubmatch(page, -1) {
+			if !strings.HasPrefix(asset[1], "/docs/assets/") {
+				t.Errorf("%s: asset %s not served by the app", renderer, asset[1])
+				continue
+			}
+			if w := serve(mux, httptest.NewRequest("GET", asset[1], nil)); w.Code != 200 {
+				t.Errorf("%s: %s answered %d", renderer, asset[1], w.Code)
+			}
+		}
+	}
+}
+
+func TestOpenAPIPathParameters(t *testing.T) {
+	defer spout.Reset()
+	spout.Reset()
+	spout.MakeHandler(http.NewServeMux(), "GET /orders/{id}", LegacyOrders)
+	spout.MakeHandler(http.NewServeMux(), "GET /files/{path...}", LegacyOrders)
+
+	spec := loadSpec(t)
+	if err := spec.Validate(context.Background()); err != nil {
+		t.Fatalf("spec with path parameters is invalid: %v", err)
+	}
+	files := spec.Paths.Find("/files/{path}")
+	if files == nil {
+		t.Fatalf("{path...} not turned into {path}: %v", spec.Paths.InMatchingOrder())
+	}
+	id := spec.Paths.Find("/orders/{id}").Get.Parameters.GetByInAndName("path", "id")
+	if id == nil || !id.Required {
+		t.Errorf("id not declared as a required path parameter: %+v", id)
+	}
+}
+
+func TestRegisterWhileServingDocs(t *testing.T) {
+	defer spout.Reset()
+	spout.Reset()
+	mux := http.NewServeMux()
+	spout.ServeDocs(mux)
+
+	// like a second Register running while the first mux serves its spec
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			spout.MakeHandler(http.NewServeMux(), "GET /racing/orders", LegacyOrders)
+			spout.Reset()
+		}
+	}()
+	for range 50 {
+		if w := serve(mux, httptest.NewRequest("GET", "/swagger.json", nil)); w.Code != 200 {
+			t.Fatalf("spec not served: %d", w.Code)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh-lucas/mug/pkg/health"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	defer health.MarkServing()
+	cfg := spout.ServerConfig{
+		ShutdownTimeout: 5 * time.Second,
+		Socket:          filepath.Join(t.TempDir(), "mug.sock"),
+	}
+	entered, release := make(chan struct{}), make(chan struct{})
+	srv := spout.NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("drained"))
+	}), cfg)
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	served := make(chan error, 1)
+	go func() { served <- spout.ServeContext(ctx, srv, cfg) }()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", cfg.Socket)
+		},
+	}}
+	answered := make(chan string, 1)
+	go func() {
+		// the socket may not be listening yet
+		for range 50 {
+			res, err := client.Get("http://mug/slow")
+			if err != nil {
+				time.Sleep(20 * time.Millisecond)
+				continue
+			}
+			body, _ := io.ReadAll(res.Body)
+			answered <- string(body)
+			return
+		}
+		answered <- "never connected"
+	}()
+
+	<-entered
+	shutdown()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if body := <-answered; body != "drained" {
+		t.Errorf("in-flight request not drained, got %q", body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("shutdown returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't stop when its context was done")
+	}
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/spout"
+)
+
+type PanelSession struct {
+	User   string `json:"user"`
+	Visits int    `json:"visits"`
+}
+
+type PanelInput struct {
+	mug.SessionAuth[PanelSession]
+}
+
+func Visit(input PanelInput) (int, PanelSession) {
+	input.Session.Visits++
+	if err := input.Save(); err != nil {
+		return http.StatusInternalServerError, PanelSession{}
+	}
+	return http.StatusOK, input.Session
+}
+
+func TestSessionAuth(t *testing.T) {
+	defer mug.ConfigureSessions(mug.SessionConfig{})
+	files, err := mug.NewFileSessions(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err := mug.NewCookieSessions("a secret of at least thirty-two characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]mug.SessionStore{"memory": mug.NewMemorySessions(), "file": files, "cookie": cookies}
+
+	for name, store := range stores {
+		mug.ConfigureSessions(mug.SessionConfig{Store: store, Insecure: true})
+		handler := spout.ConvertHandler(Visit)
+
+		w := httptest.NewRecorder()
+		csrf, err := mug.StartSession(w, httptest.NewRequest("POST", "/login", nil), PanelSession{User: "ana"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		session := w.Result().Cookies()[0]
+		visit := func(method, token string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(method, "/visit", nil)
+			r.AddCookie(session)
+			if token != "" {
+				r.Header.Set("X-CSRF-Token", token)
+			}
+			w := serve(handler, r)
+			// cookie sessions are sealed again on each save
+			for _, c := range w.Result().Cookies() {
+				session = c
+			}
+			return w
+		}
+
+		if w := visit("GET", ""); w.Code != 200 {
+			t.Errorf("%s: session rejected: %d %s", name, w.Code, w.Body)
+		}
+		if w := visit("POST", ""); w.Code != http.StatusForbidden {
+			t.Errorf("%s: POST without a CSRF token: %d", name, w.Code)
+		}
+		if w := visit("POST", "forged"); w.Code != http.StatusForbidden {
+			t.Errorf("%s: POST with a forged CSRF token: %d", name, w.Code)
+		}
+		var got PanelSession
+		w = visit("POST", csrf)
+		jsoniter.Unmarshal(w.Body.Bytes(), &got)
+		if w.Code != 200 || got.User != "ana" || got.Visits != 2 {
+			t.Errorf("%s: session not saved: %d %s", name, w.Code, w.Body)
+		}
+
+		forged := httptest.NewRequest("GET", "/visit", nil)
+		forged.AddCookie(&http.Cookie{Name: "mug_session", Value: "../../etc/passwd"})
+		if w := serve(handler, forged); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: forged cookie accepted: %d", name, w.Code)
+		}
+		if w := serve(handler, httptest.NewRequest("GET", "/visit", nil)); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: no cookie accepted: %d", name, w.Code)
+		}
+
+		logout := httptest.NewRequest("POST", "/logout", nil)
+		logout.AddCookie(session)
+		if err := mug.EndSession(httptest.NewRecorder(), logout); err != nil {
+			t.Fatal(err)
+		}
+		// a copy of a cookie session stays valid until it expires
+		if w := visit("GET", ""); name != "cookie" && w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: ended session accepted: %d", name, w.Code)
+		}
+	}
+
+	spout.MakeRoute(http.NewServeMux(), "POST /panel/visits", spout.Meta{}, Visit)
+	scheme := loadSpec(t).Components.SecuritySchemes["sessionCookie"]
+	if scheme == nil || scheme.Value.In != "cookie" || scheme.Value.Name != "mug_session" {
+		t.Errorf("session cookie not documented: %+v", scheme)
+	}
+}
+
+type NoteInput struct {
+	mug.SessionAuth[PanelSession]
+	mug.Multipart[struct {
+		Text string `form:"text"`
+	}]
+}
+
+func PostNote(input NoteInput) (int, string) {
+	return http.StatusOK, input.Body.Text
+}
+
+func TestSessionCSRFForms(t *testing.T) {
+	defer mug.ConfigureSessions(mug.SessionConfig{})
+	defer func(size int64) { mug.MaxFormSize = size }(mug.MaxFormSize)
+	mug.ConfigureSessions(mug.SessionConfig{Store: mug.NewMemorySessions(), Insecure: true})
+	w := httptest.NewRecorder()
+	csrf, err := mug.StartSession(w, httptest.NewRequest("POST", "/login", nil), PanelSession{User: "ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := w.Result().Cookies()[0]
+	handler := spout.ConvertHandler(PostNote)
+	post := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/notes", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.AddCookie(session)
+		return serve(handler, r)
+	}
+	multipartBody := func(token string) (string, []byte) {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		form.WriteField("csrf_token", token)
+		form.WriteField("text", "hi")
+		form.Close()
+		return form.FormDataContentType(), body.Bytes()
+	}
+
+	urlencoded := "application/x-www-form-urlencoded"
+	if w := post(urlencoded, []byte(url.Values{"csrf_token": {csrf}, "text": {"hi"}}.Encode())); w.Code != 200 || w.Body.String() != "\"hi\"\n" {
+		t.Errorf("url-encoded CSRF field refused: %d %s", w.Code, w.Body)
+	}
+	if w := post(multipartBody(csrf)); w.Code != 200 || w.Body.String() != "\"hi\"\n" {
+		t.Errorf("multipart CSRF field refused: %d %s", w.Code, w.Body)
+	}
+	if w := post(multipartBody("forged")); w.Code != http.StatusForbidden {
+		t.Errorf("multipart with a forged CSRF token: %d", w.Code)
+	}
+
+	// reading the token doesn't lift the form's size limit
+	mug.MaxFormSize = 64
+	big := url.Values{"csrf_token": {csrf}, "text": {strings.Repeat("a", 100)}}.Encode()
+	if w := post(urlencoded, []byte(big)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("url-encoded body beyond MaxFormSize: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sh-lucas/mug/pkg/mug"
+)
+
+func TestIssueToken(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	file := filepath.Join(t.TempDir(), "private.pem")
+	os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err := mug.ConfigureJWT(mug.JWTConfig{PrivateKeyFile: file, Issuer: "https://id.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// verified with the public part of the private key, issuer included
+	token, err := mug.IssueToken(&jwt.RegisteredClaims{Subject: "ana"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(token); w.Code != 200 || w.Body.String() != "\"ana\"\n" {
+		t.Errorf("issued token rejected: %d %s", w.Code, w.Body)
+	}
+	if _, err := mug.IssueToken(jwt.MapClaims{"sub": "bob"}, time.Minute); err != nil {
+		t.Errorf("map claims not issued: %v", err)
+	}
+	if _, err := mug.IssueToken(jwt.RegisteredClaims{Subject: "carla"}, time.Minute); err == nil {
+		t.Errorf("claims passed by value can't be stamped")
+	}
+
+	if err := mug.RevokeToken(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(token); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token accepted: %d", w.Code)
+	}
+}
+
+func TestIssueTokenVerifyOnly(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	defer func(secret string) { mug.JWT_TOKEN_SECRET = secret }(mug.JWT_TOKEN_SECRET)
+	mug.JWT_TOKEN_SECRET = "s3cr3t"
+	public, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(public)
+	file := filepath.Join(t.TempDir(), "public.pem")
+	os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+
+	// only a public key verifies the tokens: HS256 ones would be refused
+	if err := mug.ConfigureJWT(mug.JWTConfig{PublicKeyFile: file}); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := mug.IssueToken(&jwt.RegisteredClaims{Subject: "ana"}, time.Minute); err == nil {
+		t.Errorf("issued a token its verifier refuses: %s", token)
+	}
+	tokens := mug.Refresher{Claims: func(ctx context.Context, subject string) (jwt.Claims, error) {
+		return &jwt.RegisteredClaims{Subject: subject}, nil
+	}}
+	if _, err := tokens.Issue(context.Background(), &jwt.RegisteredClaims{Subject: "ana"}); err == nil {
+		t.Errorf("refresher issued tokens its verifier refuses")
+	}
+
+	// unless the secret is accepted too
+	if err := mug.ConfigureJWT(mug.JWTConfig{PublicKeyFile: file, Secret: "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := mug.IssueToken(&jwt.RegisteredClaims{Subject: "ana"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(token); w.Code != 200 {
+		t.Errorf("HS256 token rejected: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRefresher(t *testing.T) {
+	defer mug.ConfigureJWT(mug.JWTConfig{})
+	mug.ConfigureJWT(mug.JWTConfig{Secret: "s3cr3t"})
+	ctx := context.Background()
+	tokens := mug.Refresher{Claims: func(ctx context.Context, subject string) (jwt.Claims, error) {
+		return &jwt.RegisteredClaims{Subject: subject}, nil
+	}}
+
+	login, err := tokens.Issue(ctx, &jwt.RegisteredClaims{Subject: "dani"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(login.AccessToken); w.Code != 200 {
+		t.Errorf("access token rejected: %d %s", w.Code, w.Body)
+	}
+	if w := bearer(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token accepted as an access token: %d", w.Code)
+	}
+
+	refreshed, err := tokens.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(refreshed.AccessToken); w.Code != 200 || w.Body.String() != "\"dani\"\n" {
+		t.Errorf("refreshed access token rejected: %d %s", w.Code, w.Body)
+	}
+	if _, err := tokens.Refresh(ctx, refreshed.AccessToken); err == nil {
+		t.Errorf("access token accepted as a refresh token")
+	}
+
+	// reusing the first refresh token revokes the whole login
+	if _, err := tokens.Refresh(ctx, login.RefreshToken); !errors.Is(err, mug.ErrTokenRevoked) {
+		t.Errorf("reused refresh token: %v", err)
+	}
+	if _, err := tokens.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, mug.ErrTokenRevoked) {
+		t.Errorf("refresh token of a revoked login: %v", err)
+	}
+}
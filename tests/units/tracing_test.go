@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sh-lucas/mug/pkg/mug"
+	"github.com/sh-lucas/mug/pkg/rabbit"
+	"github.com/sh-lucas/mug/pkg/spout"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type tracedInput struct {
+	mug.Context
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	mux := http.NewServeMux()
+	spout.MakeHandler(mux, "POST /traced/{id}", func(input tracedInput) (int, string) {
+		if !trace.SpanContextFromContext(input.Ctx).IsValid() {
+			t.Error("handler got a context without span")
+		}
+		rabbit.SendContext(input.Ctx, "traced", "hi")
+		return 500, "oops"
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest("POST", "/traced/1", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	serve(mux, r)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the producer and server spans, got %d", len(spans))
+	}
+	producer, server := spans[0], spans[1]
+
+	if server.Name() != "POST /traced/{id}" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected server span %q (%s)", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != traceID {
+		t.Errorf("incoming traceparent not continued, got trace %s", server.SpanContext().TraceID())
+	}
+	if server.Status().Code.String() != "Error" {
+		t.Errorf("5xx not marked as an error: %v", server.Status())
+	}
+
+	if producer.SpanKind() != trace.SpanKindProducer || producer.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("producer span %q not a child of the request", producer.Name())
+	}
+}